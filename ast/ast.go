@@ -0,0 +1,215 @@
+// Package ast declares the types used to represent the syntax tree of a YRM
+// document. Unlike the map returned by yrm.Parse, the tree keeps the order of
+// keys, the comments and the position of everything in the input.
+package ast
+
+import (
+	"strings"
+
+	"github.com/doctordesh/yrm/token"
+)
+
+// Node is implemented by all nodes in the tree
+type Node interface {
+	Pos() token.Position // position of the first byte of the node
+	End() int            // offset of the first byte after the node
+}
+
+// Comment is a single '// ...' comment
+type Comment struct {
+	Token token.Token
+}
+
+func (self *Comment) Pos() token.Position { return self.Token.Position }
+func (self *Comment) End() int            { return self.Token.End }
+
+// CommentGroup is a run of comments on consecutive lines
+type CommentGroup struct {
+	List []*Comment
+}
+
+func (self *CommentGroup) Pos() token.Position { return self.List[0].Pos() }
+func (self *CommentGroup) End() int            { return self.List[len(self.List)-1].End() }
+
+// Text returns the text of the comments without the comment markers, one
+// line per comment
+func (self *CommentGroup) Text() string {
+	if self == nil {
+		return ""
+	}
+
+	lines := make([]string, len(self.List))
+	for i, c := range self.List {
+		text := strings.TrimPrefix(c.Token.Literal, "//")
+		lines[i] = strings.TrimPrefix(text, " ")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// File is a whole document
+type File struct {
+	Map      *MapNode        // the top level map, which may be empty
+	Comments []*CommentGroup // every comment in the document, in order
+	EOF      token.Position  // position of the end of the input
+}
+
+func (self *File) Pos() token.Position {
+	return token.Position{Filename: self.EOF.Filename, Offset: 0, Line: 1, Column: 1}
+}
+func (self *File) End() int { return self.EOF.Offset }
+
+// MapNode is a map, either written as a block of 'key: value' lines or
+// inline as '{key: value, ...}'
+type MapNode struct {
+	Open    token.Token // '{' of a flow map, zero for a block map
+	Entries []*KeyValue
+	Close   token.Token // '}' of a flow map, zero for a block map
+}
+
+// Flow reports whether the map is written inline
+func (self *MapNode) Flow() bool { return self.Open.TokenType == token.LEFT_BRACE }
+
+func (self *MapNode) Pos() token.Position {
+	if self.Flow() || len(self.Entries) == 0 {
+		return self.Open.Position
+	}
+	return self.Entries[0].Pos()
+}
+
+func (self *MapNode) End() int {
+	if self.Flow() || len(self.Entries) == 0 {
+		return self.Close.End
+	}
+	return self.Entries[len(self.Entries)-1].End()
+}
+
+// Lookup returns the entry for the key, or nil if there is none
+func (self *MapNode) Lookup(key string) *KeyValue {
+	for _, e := range self.Entries {
+		if e.Key.Literal == key {
+			return e
+		}
+	}
+	return nil
+}
+
+// KeyValue is one entry in a map
+type KeyValue struct {
+	Doc   *CommentGroup // comments on the lines right above, or nil
+	Key   token.Token
+	Colon token.Token
+	Value Node // *ScalarNode, *MapNode or *ListNode
+}
+
+func (self *KeyValue) Pos() token.Position { return self.Key.Position }
+func (self *KeyValue) End() int            { return self.Value.End() }
+
+// ListNode is a list, either written as a block of '- value' lines or
+// inline as '[value, ...]'
+type ListNode struct {
+	Open  token.Token // '[' of a flow list, zero for a block list
+	Items []*ListItem
+	Close token.Token // ']' of a flow list, zero for a block list
+}
+
+// Flow reports whether the list is written inline
+func (self *ListNode) Flow() bool { return self.Open.TokenType == token.LEFT_BRACKET }
+
+func (self *ListNode) Pos() token.Position {
+	if self.Flow() || len(self.Items) == 0 {
+		return self.Open.Position
+	}
+	return self.Items[0].Pos()
+}
+
+func (self *ListNode) End() int {
+	if self.Flow() || len(self.Items) == 0 {
+		return self.Close.End
+	}
+	return self.Items[len(self.Items)-1].End()
+}
+
+// ListItem is one item in a list
+type ListItem struct {
+	Doc   *CommentGroup // comments on the lines right above, or nil
+	Dash  token.Token   // '-' of an item in a block list, zero in a flow list
+	Value Node          // *ScalarNode, *MapNode or *ListNode
+}
+
+func (self *ListItem) Pos() token.Position {
+	if self.Dash.TokenType == token.DASH {
+		return self.Dash.Position
+	}
+	return self.Value.Pos()
+}
+func (self *ListItem) End() int { return self.Value.End() }
+
+// ScalarNode is a single value, e.g. a number or a string
+type ScalarNode struct {
+	Token token.Token
+	Value interface{} // the Go value of the token, e.g. int or string
+}
+
+func (self *ScalarNode) Pos() token.Position { return self.Token.Position }
+func (self *ScalarNode) End() int            { return self.Token.End }
+
+// Value returns the Go value of the node: map[string]interface{} for maps,
+// []interface{} for lists and the value of the token for scalars
+func Value(node Node) interface{} {
+	switch n := node.(type) {
+	case *File:
+		return Value(n.Map)
+	case *MapNode:
+		m := make(map[string]interface{}, len(n.Entries))
+		for _, e := range n.Entries {
+			m[e.Key.Literal] = Value(e.Value)
+		}
+		return m
+	case *ListNode:
+		l := make([]interface{}, len(n.Items))
+		for i, item := range n.Items {
+			l[i] = Value(item.Value)
+		}
+		return l
+	case *ScalarNode:
+		return n.Value
+	}
+
+	return nil
+}
+
+// Inspect walks the tree in depth-first order, calling f for each node. If f
+// returns false, the children of the node are skipped.
+func Inspect(node Node, f func(Node) bool) {
+	if f(node) == false {
+		return
+	}
+
+	switch n := node.(type) {
+	case *File:
+		Inspect(n.Map, f)
+	case *MapNode:
+		for _, e := range n.Entries {
+			Inspect(e, f)
+		}
+	case *KeyValue:
+		if n.Doc != nil {
+			Inspect(n.Doc, f)
+		}
+		Inspect(n.Value, f)
+	case *ListNode:
+		for _, item := range n.Items {
+			Inspect(item, f)
+		}
+	case *ListItem:
+		if n.Doc != nil {
+			Inspect(n.Doc, f)
+		}
+		Inspect(n.Value, f)
+	case *CommentGroup:
+		for _, c := range n.List {
+			Inspect(c, f)
+		}
+	}
+}
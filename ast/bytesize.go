@@ -0,0 +1,83 @@
+package ast
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ByteSize is a number of bytes, written with a unit like 512KiB or 2GB
+type ByteSize int64
+
+// byteUnits are the units of a byte size, largest first
+var byteUnits = []struct {
+	name string
+	size int64
+}{
+	{"EiB", 1 << 60},
+	{"EB", 1e18},
+	{"PiB", 1 << 50},
+	{"PB", 1e15},
+	{"TiB", 1 << 40},
+	{"TB", 1e12},
+	{"GiB", 1 << 30},
+	{"GB", 1e9},
+	{"MiB", 1 << 20},
+	{"MB", 1e6},
+	{"KiB", 1 << 10},
+	{"KB", 1e3},
+	{"B", 1},
+}
+
+// IsByteUnit reports whether unit is one of the units of a byte size
+func IsByteUnit(unit string) bool {
+	for _, u := range byteUnits {
+		if u.name == unit {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseByteSize parses a decimal number followed by a unit, e.g. 1.5GiB. The
+// number may have a fraction as long as the size is a whole number of bytes.
+func ParseByteSize(s string) (ByteSize, error) {
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z'
+	})
+	if i < 0 || IsByteUnit(s[i:]) == false {
+		return 0, fmt.Errorf("byte size %s has no unit", s)
+	}
+
+	number, unit := strings.ReplaceAll(s[:i], "_", ""), s[i:]
+	n, ok := new(big.Rat).SetString(number)
+	if ok == false {
+		return 0, fmt.Errorf("invalid byte size %s", s)
+	}
+
+	for _, u := range byteUnits {
+		if u.name == unit {
+			n.Mul(n, new(big.Rat).SetInt64(u.size))
+		}
+	}
+
+	if n.IsInt() == false {
+		return 0, fmt.Errorf("byte size %s is not a whole number of bytes", s)
+	}
+	if n.Num().IsInt64() == false {
+		return 0, fmt.Errorf("byte size %s overflows int64", s)
+	}
+
+	return ByteSize(n.Num().Int64()), nil
+}
+
+// String returns the size in the largest unit that it's a whole number of,
+// e.g. 512KiB
+func (self ByteSize) String() string {
+	for _, u := range byteUnits {
+		if self != 0 && int64(self)%u.size == 0 {
+			return fmt.Sprintf("%d%s", int64(self)/u.size, u.name)
+		}
+	}
+	return "0B"
+}
@@ -0,0 +1,39 @@
+package ast
+
+import (
+	"fmt"
+	"time"
+)
+
+// Date is a calendar date without a time or time zone, written like
+// 2024-01-15
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// ParseDate parses a date written as YYYY-MM-DD
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return Date{}, err
+	}
+	return DateOf(t), nil
+}
+
+// DateOf returns the date that t falls on, in t's location
+func DateOf(t time.Time) Date {
+	year, month, day := t.Date()
+	return Date{Year: year, Month: month, Day: day}
+}
+
+// In returns the time at midnight at the start of the date in loc
+func (self Date) In(loc *time.Location) time.Time {
+	return time.Date(self.Year, self.Month, self.Day, 0, 0, 0, 0, loc)
+}
+
+// String returns the date as YYYY-MM-DD
+func (self Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", self.Year, self.Month, self.Day)
+}
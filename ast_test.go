@@ -0,0 +1,79 @@
+package yrm
+
+import (
+	"testing"
+
+	"github.com/doctordesh/yrm/ast"
+	check "gitlab.com/MaxIV/lib-maxiv-go-check"
+)
+
+var astInput = `// The host
+// to connect to
+host: "localhost"
+
+// unrelated comment
+
+ports:
+	// the main port
+	http: 8888
+	grpc: 9999
+servers:
+	- {name: "a", tags: [1, 2]}
+	-
+		name: "b"
+// trailing
+`
+
+func TestParseAST(t *testing.T) {
+	file, err := ParseAST(astInput)
+	check.OK(t, err)
+
+	// the keys are kept in order
+	m := file.Map
+	check.Equals(t, 3, len(m.Entries))
+	check.Equals(t, "host", m.Entries[0].Key.Literal)
+	check.Equals(t, "ports", m.Entries[1].Key.Literal)
+	check.Equals(t, "servers", m.Entries[2].Key.Literal)
+
+	// comments right above a key are its doc
+	check.Equals(t, "The host\nto connect to", m.Entries[0].Doc.Text())
+	check.Assert(t, m.Entries[1].Doc == nil)
+	check.Equals(t, 4, len(file.Comments))
+	check.Equals(t, "unrelated comment", file.Comments[1].Text())
+	check.Equals(t, "trailing", file.Comments[3].Text())
+
+	// positions point into the input
+	host := m.Entries[0]
+	check.Equals(t, 3, host.Pos().Line)
+	check.Equals(t, `host: "localhost"`, astInput[host.Pos().Offset:host.End()])
+
+	ports := m.Entries[1].Value.(*ast.MapNode)
+	check.Assert(t, ports.Flow() == false)
+	check.Equals(t, "the main port", ports.Entries[0].Doc.Text())
+	check.Equals(t, "http: 8888\n\tgrpc: 9999", astInput[ports.Pos().Offset:ports.End()])
+
+	servers := m.Entries[2].Value.(*ast.ListNode)
+	check.Equals(t, 2, len(servers.Items))
+	first := servers.Items[0].Value.(*ast.MapNode)
+	check.Assert(t, first.Flow())
+	check.Equals(t, `{name: "a", tags: [1, 2]}`, astInput[first.Pos().Offset:first.End()])
+	check.Equals(t, "- {name: \"a\", tags: [1, 2]}", astInput[servers.Items[0].Pos().Offset:servers.Items[0].End()])
+
+	tags := first.Lookup("tags").Value.(*ast.ListNode)
+	check.Equals(t, 2, tags.Items[1].Value.(*ast.ScalarNode).Value)
+
+	// the map is derived from the tree
+	exp, err := Parse(astInput)
+	check.OK(t, err)
+	check.Equals(t, exp, ast.Value(file))
+
+	// count the scalars
+	n := 0
+	ast.Inspect(file, func(node ast.Node) bool {
+		if _, ok := node.(*ast.ScalarNode); ok {
+			n += 1
+		}
+		return true
+	})
+	check.Equals(t, 7, n)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/doctordesh/yrm"
+)
+
+// converters holds the conversions, by the formats converted from and to
+var converters = map[[2]string]func([]byte) ([]byte, error){
+	{"json", "yrm"}: yrm.FromJSON,
+	{"yrm", "json"}: yrm.ToJSON,
+	{"yaml", "yrm"}: yrm.FromYAML,
+	{"yrm", "yaml"}: yrm.ToYAML,
+
+	{"yrm", "toml"}:       yrm.ToTOML,
+	{"yrm", "ini"}:        yrm.ToINI,
+	{"yrm", "properties"}: yrm.ToProperties,
+}
+
+// runConvert converts the file, or stdin, between YRM and other formats
+func runConvert(args []string) int {
+	flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	from := flags.String("from", "yrm", "format of the input")
+	to := flags.String("to", "json", "format of the output")
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+
+	if flags.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "usage: yrm convert [--from format] [--to format] [file]")
+		return 2
+	}
+
+	convert, ok := converters[[2]string{*from, *to}]
+	if ok == false {
+		fmt.Fprintf(os.Stderr, "yrm convert: cannot convert from %s to %s\n", *from, *to)
+		return 2
+	}
+
+	in, err := readInput(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "yrm convert: %v\n", err)
+		return 2
+	}
+
+	res, err := convert(in.src)
+	if err != nil {
+		reportError(in, err)
+		return 1
+	}
+
+	os.Stdout.Write(res)
+	return 0
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/doctordesh/yrm"
+)
+
+// runFmt formats the files, or stdin if there are none. The exit code is 1
+// if -l lists any file or a file doesn't parse, and 2 if a file couldn't be
+// read or written.
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	write := flags.Bool("w", false, "write the result to the file instead of stdout")
+	list := flags.Bool("l", false, "list the files that are not formatted")
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "yrm fmt: cannot use -w with stdin")
+			return 2
+		}
+
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "yrm fmt: %v\n", err)
+			return 2
+		}
+
+		return formatFile("<stdin>", src, *list, false)
+	}
+
+	code := 0
+	for _, filename := range flags.Args() {
+		src, err := ioutil.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "yrm fmt: %v\n", err)
+			code = 2
+			continue
+		}
+
+		c := formatFile(filename, src, *list, *write)
+		if c > code {
+			code = c
+		}
+	}
+
+	return code
+}
+
+// formatFile formats one file and returns its exit code
+func formatFile(filename string, src []byte, list, write bool) int {
+	res, err := yrm.Format(src)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s:%v\n", filename, err)
+		return 1
+	}
+
+	changed := bytes.Equal(src, res) == false
+	code := 0
+
+	if list && changed {
+		fmt.Println(filename)
+		code = 1
+	}
+
+	if write && changed {
+		info, err := os.Stat(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "yrm fmt: %v\n", err)
+			return 2
+		}
+
+		err = ioutil.WriteFile(filename, res, info.Mode().Perm())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "yrm fmt: %v\n", err)
+			return 2
+		}
+	}
+
+	if list == false && write == false {
+		os.Stdout.Write(res)
+	}
+
+	return code
+}
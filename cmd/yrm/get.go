@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/doctordesh/yrm"
+)
+
+// runGet prints the value at a dotted path, e.g. 'ports.http'. Scalars are
+// printed as they are written in YRM but with strings unquoted, maps and
+// lists as JSON or, with '-o yrm', as YRM. The exit code is 1 if the path
+// doesn't exist.
+func runGet(args []string) int {
+	flags := flag.NewFlagSet("get", flag.ContinueOnError)
+	output := flags.String("o", "json", "output format of maps and lists, 'json' or 'yrm'")
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 2 || (*output != "json" && *output != "yrm") {
+		fmt.Fprintln(os.Stderr, "usage: yrm get [-o json|yrm] <file|-> <key.path>")
+		return 2
+	}
+
+	in, err := readInput(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "yrm get: %v\n", err)
+		return 2
+	}
+
+	doc, err := yrm.ParseDocument(in.src)
+	if err != nil {
+		reportError(in, err)
+		return 1
+	}
+
+	path := flags.Arg(1)
+	v, err := doc.Get(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var b []byte
+	switch v.(type) {
+	case map[string]interface{}:
+		if *output == "yrm" {
+			b, err = yrm.Marshal(v)
+			break
+		}
+		b, err = doc.GetJSON(path)
+	case []interface{}:
+		if *output == "yrm" {
+			// a document can't be a list, it's written under its key
+			keys := yrm.SplitPath(path)
+			b, err = yrm.Marshal(map[string]interface{}{keys[len(keys)-1]: v})
+			break
+		}
+		b, err = doc.GetJSON(path)
+	case string:
+		b = []byte(fmt.Sprintln(v))
+	default:
+		var s string
+		s, err = yrm.MarshalScalar(v)
+		b = []byte(s + "\n")
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "yrm get: %v\n", err)
+		return 1
+	}
+
+	os.Stdout.Write(b)
+	return 0
+}
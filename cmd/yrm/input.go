@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/doctordesh/yrm"
+	"github.com/doctordesh/yrm/lexer"
+)
+
+// input is a file given on the command line, or stdin
+type input struct {
+	name string
+	src  []byte
+}
+
+// readInputs reads the files, or stdin if there are none or the name is
+// '-'. Files that can't be read are reported and skipped, ok is false if
+// there were any.
+func readInputs(cmd string, filenames []string) (inputs []input, ok bool) {
+	if len(filenames) == 0 {
+		filenames = []string{"-"}
+	}
+
+	ok = true
+	for _, filename := range filenames {
+		in, err := readInput(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "yrm %s: %v\n", cmd, err)
+			ok = false
+			continue
+		}
+
+		inputs = append(inputs, in)
+	}
+
+	return inputs, ok
+}
+
+// readInput reads the file, or stdin if the name is empty or '-'
+func readInput(filename string) (input, error) {
+	if filename == "" || filename == "-" {
+		src, err := ioutil.ReadAll(os.Stdin)
+		return input{name: "<stdin>", src: src}, err
+	}
+
+	src, err := ioutil.ReadFile(filename)
+	return input{name: filename, src: src}, err
+}
+
+// reportError prints an error from handling the input, prefixed by its name.
+// Errors with a position read 'file:line:column: message'.
+func reportError(in input, err error) {
+	var syntaxErr *yrm.SyntaxError
+	var duplicateErr *yrm.DuplicateKeyError
+	var indentationErr *yrm.IndentationError
+	var lexErr *lexer.Error
+
+	if errors.As(err, &syntaxErr) || errors.As(err, &duplicateErr) || errors.As(err, &indentationErr) || errors.As(err, &lexErr) {
+		fmt.Fprintf(os.Stderr, "%s:%v\n", in.name, err)
+		return
+	}
+
+	var convertErrs yrm.ConvertErrors
+	if errors.As(err, &convertErrs) {
+		for _, err := range convertErrs {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", in.name, err)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: %v\n", in.name, err)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	check "gitlab.com/MaxIV/lib-maxiv-go-check"
+)
+
+// captureStderr runs f and returns what it writes to stderr
+func captureStderr(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	check.OK(t, err)
+
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+
+	f()
+	w.Close()
+
+	b, err := ioutil.ReadAll(r)
+	check.OK(t, err)
+	return string(b)
+}
+
+func TestReportErrorPosition(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "bad.yrm")
+	err := ioutil.WriteFile(filename, []byte("a: \"open\n"), 0644)
+	check.OK(t, err)
+
+	type row struct {
+		run func([]string) int
+		exp string
+	}
+
+	table := []row{
+		{runTokens, filename + ":1:4: unterminated quoted string\n"},
+		{runValidate, filename + ":1:4: unterminated quoted string\n"},
+	}
+
+	// the tokens before the error are printed to stdout
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	check.OK(t, err)
+	defer devNull.Close()
+
+	stdout := os.Stdout
+	os.Stdout = devNull
+	defer func() { os.Stdout = stdout }()
+
+	for i, r := range table {
+		var code int
+		out := captureStderr(t, func() { code = r.run([]string{filename}) })
+
+		check.EqualsWithMessage(t, 1, code, "row %d", i)
+		check.EqualsWithMessage(t, r.exp, out, "row %d", i)
+	}
+}
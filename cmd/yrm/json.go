@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/doctordesh/yrm"
+)
+
+// runToJSON prints the file, or stdin, as JSON with the keys in the order
+// of the input
+func runToJSON(args []string) int {
+	flags := flag.NewFlagSet("to-json", flag.ContinueOnError)
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+
+	if flags.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "usage: yrm to-json [file]")
+		return 2
+	}
+
+	in, err := readInput(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "yrm to-json: %v\n", err)
+		return 2
+	}
+
+	b, err := yrm.ToJSON(in.src)
+	if err != nil {
+		reportError(in, err)
+		return 1
+	}
+
+	os.Stdout.Write(b)
+	return 0
+}
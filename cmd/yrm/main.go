@@ -1,32 +1,53 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-
-	"github.com/doctordesh/yrm"
+	"os"
 )
 
-var input = `
-// a comment
-host: "localhost"
-ports:
-	http: 8888
-	grpc: 9999
-startup_delay: 5.5
-env: "production"
-verbose: true
-production: false
+// command runs a subcommand with its arguments and returns the exit code
+type command func(args []string) int
+
+var commands = map[string]command{
+	"fmt":      runFmt,
+	"validate": runValidate,
+	"to-json":  runToJSON,
+	"get":      runGet,
+	"set":      runSet,
+	"tokens":   runTokens,
+	"convert":  runConvert,
+}
+
+var usage = `usage: yrm <command> [arguments]
+
+commands:
+	fmt [-w] [-l] [files...]   format files, or stdin, in canonical form
+	validate [files...]        report errors in files, or stdin
+	to-json [file]             print a file, or stdin, as JSON
+	get [-o json|yrm] <file|-> <key.path>
+	                           print the value at a path
+	set <file> <key.path> <value>
+	                           set the value at a path in the file
+	tokens [file]              print the tokens of a file, or stdin
+	convert [--from format] [--to format] [file]
+	                           convert a file, or stdin, between yrm and json or
+	                           yaml, or from yrm to toml, ini or properties
+
+Without files, or with '-', the input is read from stdin. The exit code is 1
+for invalid input and 2 for bad usage or files that can't be read.
 `
 
 func main() {
-	res, err := yrm.Parse(input)
-	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		return
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
 	}
 
-	b, _ := json.MarshalIndent(res, "", "    ")
+	cmd, ok := commands[os.Args[1]]
+	if ok == false {
+		fmt.Fprintf(os.Stderr, "yrm: unknown command '%s'\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
 
-	fmt.Printf("%s\n", b)
+	os.Exit(cmd(os.Args[2:]))
 }
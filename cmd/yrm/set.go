@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/doctordesh/yrm"
+)
+
+// runSet sets the value at a dotted path in the file, leaving the rest of the
+// file as it is. The value is read as a YRM value, e.g. '8080', '"8080"' or
+// '[1, 2]', and anything that isn't a valid value is set as a string. Stdin
+// can't be edited, as there is no file to write back to.
+func runSet(args []string) int {
+	flags := flag.NewFlagSet("set", flag.ContinueOnError)
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 3 {
+		fmt.Fprintln(os.Stderr, "usage: yrm set <file> <key.path> <value>")
+		return 2
+	}
+
+	if flags.Arg(0) == "" || flags.Arg(0) == "-" {
+		fmt.Fprintln(os.Stderr, "yrm set: cannot edit stdin in place")
+		return 2
+	}
+
+	value, err := parseValue(flags.Arg(2))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "yrm set: %v\n", err)
+		return 2
+	}
+
+	in, err := readInput(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "yrm set: %v\n", err)
+		return 2
+	}
+
+	doc, err := yrm.ParseDocument(in.src)
+	if err != nil {
+		reportError(in, err)
+		return 1
+	}
+
+	err = doc.Set(flags.Arg(1), value)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	info, err := os.Stat(in.name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "yrm set: %v\n", err)
+		return 2
+	}
+
+	err = ioutil.WriteFile(in.name, doc.Bytes(), info.Mode().Perm())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "yrm set: %v\n", err)
+		return 2
+	}
+
+	return 0
+}
+
+// parseValue reads the argument as a YRM value, or as a string if it isn't
+// one. The value must be on a single line.
+func parseValue(arg string) (interface{}, error) {
+	if strings.ContainsAny(arg, "\r\n") {
+		return nil, errors.New("the value must be on a single line")
+	}
+
+	m, err := yrm.Parse("value: " + arg)
+	if err != nil {
+		return arg, nil
+	}
+
+	return m["value"], nil
+}
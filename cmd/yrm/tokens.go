@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/doctordesh/yrm/lexer"
+	"github.com/doctordesh/yrm/token"
+)
+
+// runTokens prints the tokens of the file, or stdin, one per line
+func runTokens(args []string) int {
+	flags := flag.NewFlagSet("tokens", flag.ContinueOnError)
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+
+	if flags.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "usage: yrm tokens [file]")
+		return 2
+	}
+
+	in, err := readInput(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "yrm tokens: %v\n", err)
+		return 2
+	}
+
+	tokens, err := lexer.New(string(in.src)).Lex()
+	for _, tok := range tokens {
+		if tok.TokenType == token.ILLEGAL {
+			continue
+		}
+		fmt.Printf("%-8s %-14s %q\n", tok.Position, tok.TokenType, tok.Literal)
+	}
+
+	if err != nil {
+		reportError(in, err)
+		return 1
+	}
+
+	return 0
+}
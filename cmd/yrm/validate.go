@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/doctordesh/yrm"
+)
+
+// runValidate parses the files, or stdin, and reports any errors. The exit
+// code is 1 if a file is invalid, and 2 if a file couldn't be read.
+func runValidate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+
+	inputs, ok := readInputs("validate", flags.Args())
+
+	code := 0
+	for _, in := range inputs {
+		_, err := yrm.ParseAST(string(in.src))
+		if err != nil {
+			reportError(in, err)
+			code = 1
+		}
+	}
+
+	if ok == false {
+		return 2
+	}
+	return code
+}
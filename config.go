@@ -0,0 +1,179 @@
+package yrm
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config wraps the result of Parse to look up values by a dotted path, e.g.
+// "servers.0.host", with list items given by their index. See SplitPath for
+// keys with dots in them.
+type Config struct {
+	m map[string]interface{}
+}
+
+// NewConfig returns a Config for the map returned by Parse
+func NewConfig(m map[string]interface{}) *Config {
+	return &Config{m: m}
+}
+
+// ParseConfig parses the input into a Config
+func ParseConfig(input string) (*Config, error) {
+	m, err := Parse(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewConfig(m), nil
+}
+
+// NotFoundError is returned by Config when there is no value at a path
+type NotFoundError struct {
+	Path []string // the path that was looked up
+	Msg  string   // why it doesn't exist
+}
+
+func (self *NotFoundError) Error() string {
+	return fmt.Sprintf("yrm: '%s' not found: %s", strings.Join(self.Path, "."), self.Msg)
+}
+
+// LookupTypeError is returned by Config when the value at a path is not of
+// the type asked for
+type LookupTypeError struct {
+	Path  []string     // the path that was looked up
+	Value string       // description of the value, e.g. "string" or "map"
+	Type  reflect.Type // the type asked for
+}
+
+func (self *LookupTypeError) Error() string {
+	return fmt.Sprintf("yrm: '%s' is %s, not %s", strings.Join(self.Path, "."), article(self.Value), self.Type)
+}
+
+// Get returns the value at the path, which is nil for null
+func (self *Config) Get(path string) (interface{}, error) {
+	keys := SplitPath(path)
+
+	var value interface{} = self.m
+	for i, key := range keys {
+		parent := "the top level"
+		if i > 0 {
+			parent = "'" + strings.Join(keys[:i], ".") + "'"
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			child, ok := v[key]
+			if ok == false {
+				return nil, &NotFoundError{Path: keys, Msg: fmt.Sprintf("no key '%s' in %s", key, parent)}
+			}
+			value = child
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, &NotFoundError{Path: keys, Msg: fmt.Sprintf("no index '%s' in %s", key, parent)}
+			}
+			value = v[index]
+		default:
+			return nil, &NotFoundError{Path: keys, Msg: fmt.Sprintf("%s is %s", parent, article(describe(v)))}
+		}
+	}
+
+	return value, nil
+}
+
+// Has reports whether there is a value at the path, which may be null
+func (self *Config) Has(path string) bool {
+	_, err := self.Get(path)
+	return err == nil
+}
+
+// IsNull reports whether the value at the path is null. It's false if there
+// is no value at the path.
+func (self *Config) IsNull(path string) bool {
+	value, err := self.Get(path)
+	return err == nil && value == nil
+}
+
+// GetString returns the string at the path
+func (self *Config) GetString(path string) (string, error) {
+	return Lookup[string](self, path)
+}
+
+// GetInt returns the int at the path
+func (self *Config) GetInt(path string) (int, error) {
+	return Lookup[int](self, path)
+}
+
+// GetFloat returns the number at the path, ints included
+func (self *Config) GetFloat(path string) (float64, error) {
+	return Lookup[float64](self, path)
+}
+
+// GetBool returns the bool at the path
+func (self *Config) GetBool(path string) (bool, error) {
+	return Lookup[bool](self, path)
+}
+
+// GetDuration returns the duration at the path, e.g. 30s
+func (self *Config) GetDuration(path string) (time.Duration, error) {
+	return Lookup[time.Duration](self, path)
+}
+
+// GetByteSize returns the byte size at the path, e.g. 512KiB
+func (self *Config) GetByteSize(path string) (ByteSize, error) {
+	return Lookup[ByteSize](self, path)
+}
+
+// GetTime returns the datetime or time of day at the path
+func (self *Config) GetTime(path string) (time.Time, error) {
+	return Lookup[time.Time](self, path)
+}
+
+// GetDate returns the date at the path, e.g. 2024-01-15
+func (self *Config) GetDate(path string) (Date, error) {
+	return Lookup[Date](self, path)
+}
+
+// GetMap returns the map at the path
+func (self *Config) GetMap(path string) (map[string]interface{}, error) {
+	return Lookup[map[string]interface{}](self, path)
+}
+
+// Lookup returns the value at the path as a T. T is one of the types
+// returned by Parse, an int is also returned as a float64.
+func Lookup[T any](cfg *Config, path string) (T, error) {
+	var zero T
+
+	value, err := cfg.Get(path)
+	if err != nil {
+		return zero, err
+	}
+
+	if t, ok := value.(T); ok {
+		return t, nil
+	}
+
+	if i, ok := value.(int); ok {
+		if f, ok := interface{}(float64(i)).(T); ok {
+			return f, nil
+		}
+	}
+
+	return zero, &LookupTypeError{
+		Path:  SplitPath(path),
+		Value: describe(value),
+		Type:  reflect.TypeOf(&zero).Elem(),
+	}
+}
+
+// article returns the description with 'a' or 'an' in front of it. 'u' is
+// left out of the vowels, for 'a uint64'.
+func article(s string) string {
+	if strings.IndexAny(s[:1], "aeio") == 0 {
+		return "an " + s
+	}
+	return "a " + s
+}
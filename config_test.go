@@ -0,0 +1,133 @@
+package yrm
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	check "gitlab.com/MaxIV/lib-maxiv-go-check"
+)
+
+var configInput = `bar:
+	bool:
+		ways: "both"
+	count: 3
+	ratio: 0.5
+	on: true
+	timeout: 30s
+	buffer: 4KiB
+	since: 2024-01-15
+servers:
+	- {host: "a"}
+unset: null
+big: 18446744073709551615
+`
+
+func TestConfig(t *testing.T) {
+	cfg, err := ParseConfig(configInput)
+	check.OK(t, err)
+
+	s, err := cfg.GetString("bar.bool.ways")
+	check.OK(t, err)
+	check.Equals(t, "both", s)
+
+	i, err := cfg.GetInt("bar.count")
+	check.OK(t, err)
+	check.Equals(t, 3, i)
+
+	f, err := cfg.GetFloat("bar.ratio")
+	check.OK(t, err)
+	check.Equals(t, 0.5, f)
+
+	// ints are floats too
+	f, err = cfg.GetFloat("bar.count")
+	check.OK(t, err)
+	check.Equals(t, 3.0, f)
+
+	b, err := cfg.GetBool("bar.on")
+	check.OK(t, err)
+	check.Equals(t, true, b)
+
+	d, err := cfg.GetDuration("bar.timeout")
+	check.OK(t, err)
+	check.Equals(t, 30*time.Second, d)
+
+	size, err := cfg.GetByteSize("bar.buffer")
+	check.OK(t, err)
+	check.Equals(t, ByteSize(4096), size)
+
+	day, err := cfg.GetDate("bar.since")
+	check.OK(t, err)
+	check.Equals(t, Date{Year: 2024, Month: time.January, Day: 15}, day)
+
+	m, err := cfg.GetMap("bar.bool")
+	check.OK(t, err)
+	check.Equals(t, map[string]interface{}{"ways": "both"}, m)
+
+	host, err := Lookup[string](cfg, "servers.0.host")
+	check.OK(t, err)
+	check.Equals(t, "a", host)
+
+	check.Assert(t, cfg.Has("bar.on"))
+	check.Assert(t, cfg.Has("bar.off") == false)
+
+	// null is there, but has no value
+	v, err := cfg.Get("unset")
+	check.OK(t, err)
+	check.Equals(t, nil, v)
+	check.Assert(t, cfg.Has("unset"))
+	check.Assert(t, cfg.IsNull("unset"))
+	check.Assert(t, cfg.IsNull("bar.on") == false)
+	check.Assert(t, cfg.IsNull("missing") == false)
+}
+
+func TestConfigQuotedPath(t *testing.T) {
+	cfg, err := ParseConfig("hosts:\n\t\"example.com\":\n\t\tport: 80\n\t\"a\\\"b\": 1\n")
+	check.OK(t, err)
+
+	port, err := cfg.GetInt(`hosts."example.com".port`)
+	check.OK(t, err)
+	check.Equals(t, 80, port)
+
+	i, err := cfg.GetInt(`hosts."a\"b"`)
+	check.OK(t, err)
+	check.Equals(t, 1, i)
+
+	check.Assert(t, cfg.Has("hosts.example.com.port") == false)
+}
+
+func TestConfigErrors(t *testing.T) {
+	cfg, err := ParseConfig(configInput)
+	check.OK(t, err)
+
+	type row struct {
+		lookup func() error
+		exp    string
+	}
+
+	table := []row{
+		{func() error { _, err := cfg.Get("foo"); return err }, "yrm: 'foo' not found: no key 'foo' in the top level"},
+		{func() error { _, err := cfg.Get("bar.bool.no"); return err }, "yrm: 'bar.bool.no' not found: no key 'no' in 'bar.bool'"},
+		{func() error { _, err := cfg.Get("servers.1"); return err }, "yrm: 'servers.1' not found: no index '1' in 'servers'"},
+		{func() error { _, err := cfg.Get("bar.count.x"); return err }, "yrm: 'bar.count.x' not found: 'bar.count' is an int"},
+		{func() error { _, err := cfg.GetInt("bar.bool.ways"); return err }, "yrm: 'bar.bool.ways' is a string, not int"},
+		{func() error { _, err := cfg.GetMap("servers"); return err }, "yrm: 'servers' is a list, not map[string]interface {}"},
+		{func() error { _, err := cfg.GetString("unset"); return err }, "yrm: 'unset' is a null, not string"},
+		{func() error { _, err := cfg.GetInt("big"); return err }, "yrm: 'big' is a uint64, not int"},
+	}
+
+	for i, r := range table {
+		err := r.lookup()
+		check.NotOK(t, err)
+		check.EqualsWithMessage(t, r.exp, err.Error(), "row %d", i)
+	}
+
+	var notFound *NotFoundError
+	_, err = cfg.Get("foo")
+	check.Assert(t, errors.As(err, &notFound))
+
+	var typeErr *LookupTypeError
+	_, err = cfg.GetBool("bar.count")
+	check.Assert(t, errors.As(err, &typeErr))
+	check.Equals(t, "int", typeErr.Value)
+}
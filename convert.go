@@ -0,0 +1,45 @@
+package yrm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConvertError is returned when converting between YRM and another format,
+// for a value that one of them cannot express
+type ConvertError struct {
+	Path []string // path to the value, list items by index
+	Line int      // line of the value in the input, if known
+	Msg  string
+}
+
+func (self *ConvertError) Error() string {
+	prefix := "yrm: "
+	if self.Line > 0 {
+		prefix = fmt.Sprintf("yrm: line %d: ", self.Line)
+	}
+
+	if len(self.Path) == 0 {
+		return prefix + "cannot convert: " + self.Msg
+	}
+	return fmt.Sprintf("%scannot convert '%s': %s", prefix, strings.Join(self.Path, "."), self.Msg)
+}
+
+// ConvertErrors holds every value of the input that cannot be converted
+type ConvertErrors []*ConvertError
+
+func (self ConvertErrors) Error() string {
+	var lines []string
+	for _, err := range self {
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (self ConvertErrors) Unwrap() []error {
+	var errs []error
+	for _, err := range self {
+		errs = append(errs, err)
+	}
+	return errs
+}
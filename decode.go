@@ -0,0 +1,326 @@
+package yrm
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InvalidUnmarshalError is returned by Unmarshal when the target is not a
+// non-nil pointer
+type InvalidUnmarshalError struct {
+	Type reflect.Type
+}
+
+func (self *InvalidUnmarshalError) Error() string {
+	if self.Type == nil {
+		return "yrm: Unmarshal(nil)"
+	}
+
+	if self.Type.Kind() != reflect.Ptr {
+		return "yrm: Unmarshal(non-pointer " + self.Type.String() + ")"
+	}
+
+	return "yrm: Unmarshal(nil " + self.Type.String() + ")"
+}
+
+// UnmarshalTypeError is returned by Unmarshal when a value in the document
+// cannot be stored in the Go value at the same path
+type UnmarshalTypeError struct {
+	Path  []string     // path to the value, list items by index
+	Value string       // description of the value, e.g. "string" or "map"
+	Type  reflect.Type // type of the Go value it could not be stored in
+}
+
+func (self *UnmarshalTypeError) Error() string {
+	return fmt.Sprintf(
+		"yrm: cannot unmarshal %s into Go value of type %s at '%s'",
+		self.Value,
+		self.Type,
+		strings.Join(self.Path, "."),
+	)
+}
+
+// Unmarshal parses the data and stores the result in the value pointed to by
+// v, which can be a struct, a map or an interface{}.
+//
+// Struct fields are matched against keys by their 'yrm' tag, or by their name
+// if they have no tag (preferring an exact match, but accepting a case
+// insensitive one). Fields tagged with "-" are ignored, as are keys without a
+// matching field. A null sets the Go value to its zero value, so it clears
+// pointers, maps, slices and structs.
+//
+//	type Config struct {
+//		Host  string `yrm:"host"`
+//		Ports struct {
+//			HTTP int `yrm:"http"`
+//		} `yrm:"ports"`
+//		Secret string `yrm:"-"`
+//	}
+func Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &InvalidUnmarshalError{Type: reflect.TypeOf(v)}
+	}
+
+	m, err := Parse(string(data))
+	if err != nil {
+		return err
+	}
+
+	return unmarshal(nil, m, rv)
+}
+
+// unmarshal stores the parsed value in the Go value rv
+func unmarshal(path []string, value interface{}, rv reflect.Value) error {
+	// null clears the value, pointers included
+	if value == nil {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+
+	// Allocate pointers on the way down
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return unmarshal(path, value, rv.Elem())
+	}
+
+	if rv.Kind() == reflect.Interface && rv.NumMethod() == 0 {
+		rv.Set(reflect.ValueOf(value))
+		return nil
+	}
+
+	typeError := &UnmarshalTypeError{
+		Path:  path,
+		Value: describe(value),
+		Type:  rv.Type(),
+	}
+
+	// a duration needs a unit, so that 30 isn't silently taken as 30ns
+	if _, ok := value.(time.Duration); rv.Type() == durationType && ok == false {
+		return typeError
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		switch rv.Kind() {
+		case reflect.Struct:
+			return unmarshalStruct(path, v, rv)
+		case reflect.Map:
+			return unmarshalMap(path, v, rv)
+		}
+	case []interface{}:
+		switch rv.Kind() {
+		case reflect.Slice:
+			slice := reflect.MakeSlice(rv.Type(), len(v), len(v))
+			for i := range v {
+				err := unmarshal(appendPath(path, strconv.Itoa(i)), v[i], slice.Index(i))
+				if err != nil {
+					return err
+				}
+			}
+			rv.Set(slice)
+			return nil
+		case reflect.Array:
+			if len(v) != rv.Len() {
+				return typeError
+			}
+			for i := range v {
+				err := unmarshal(appendPath(path, strconv.Itoa(i)), v[i], rv.Index(i))
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case string:
+		if rv.Kind() == reflect.String {
+			rv.SetString(v)
+			return nil
+		}
+	case bool:
+		if rv.Kind() == reflect.Bool {
+			rv.SetBool(v)
+			return nil
+		}
+	case int:
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if rv.OverflowInt(int64(v)) {
+				return typeError
+			}
+			rv.SetInt(int64(v))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if v < 0 || rv.OverflowUint(uint64(v)) {
+				return typeError
+			}
+			rv.SetUint(uint64(v))
+			return nil
+		case reflect.Float32, reflect.Float64:
+			rv.SetFloat(float64(v))
+			return nil
+		}
+	case time.Time:
+		if rv.Type() == timeType {
+			rv.Set(reflect.ValueOf(v))
+			return nil
+		}
+	case Date:
+		switch rv.Type() {
+		case dateType:
+			rv.Set(reflect.ValueOf(v))
+			return nil
+		case timeType:
+			rv.Set(reflect.ValueOf(v.In(time.UTC)))
+			return nil
+		}
+	case time.Duration:
+		if rv.Type() == durationType {
+			rv.SetInt(int64(v))
+			return nil
+		}
+	case ByteSize:
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if rv.OverflowInt(int64(v)) {
+				return typeError
+			}
+			rv.SetInt(int64(v))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if v < 0 || rv.OverflowUint(uint64(v)) {
+				return typeError
+			}
+			rv.SetUint(uint64(v))
+			return nil
+		}
+	case uint64:
+		switch rv.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if rv.OverflowUint(v) {
+				return typeError
+			}
+			rv.SetUint(v)
+			return nil
+		case reflect.Float32, reflect.Float64:
+			rv.SetFloat(float64(v))
+			return nil
+		}
+	case float64:
+		switch rv.Kind() {
+		case reflect.Float32, reflect.Float64:
+			if rv.OverflowFloat(v) {
+				return typeError
+			}
+			rv.SetFloat(v)
+			return nil
+		}
+	}
+
+	return typeError
+}
+
+// unmarshalStruct stores the parsed map in the fields of the struct rv
+func unmarshalStruct(path []string, m map[string]interface{}, rv reflect.Value) error {
+	fields := structFields(rv.Type())
+
+	for key, value := range m {
+		f, ok := findField(fields, key)
+		if ok == false {
+			continue
+		}
+
+		err := unmarshal(appendPath(path, key), value, rv.Field(f.index))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unmarshalMap stores the parsed map in the Go map rv, which must have
+// string keys
+func unmarshalMap(path []string, m map[string]interface{}, rv reflect.Value) error {
+	t := rv.Type()
+	if t.Key().Kind() != reflect.String {
+		return &UnmarshalTypeError{Path: path, Value: "map", Type: t}
+	}
+
+	if rv.IsNil() {
+		rv.Set(reflect.MakeMapWithSize(t, len(m)))
+	}
+
+	for key, value := range m {
+		elem := reflect.New(t.Elem()).Elem()
+		err := unmarshal(appendPath(path, key), value, elem)
+		if err != nil {
+			return err
+		}
+
+		rv.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), elem)
+	}
+
+	return nil
+}
+
+// findField returns the field for the key, preferring an exact match over a
+// case insensitive one
+func findField(fields []field, key string) (field, bool) {
+	for i := range fields {
+		if fields[i].name == key {
+			return fields[i], true
+		}
+	}
+
+	for i := range fields {
+		if strings.EqualFold(fields[i].name, key) {
+			return fields[i], true
+		}
+	}
+
+	return field{}, false
+}
+
+// describe returns the name of the kind of a parsed value, for errors
+func describe(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "map"
+	case []interface{}:
+		return "list"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case int:
+		return "int"
+	case uint64:
+		return "uint64"
+	case float64:
+		return "float"
+	case time.Duration:
+		return "duration"
+	case time.Time:
+		return "time"
+	case Date:
+		return "date"
+	case ByteSize:
+		return "byte size"
+	case nil:
+		return "null"
+	}
+
+	return fmt.Sprintf("%T", value)
+}
+
+// appendPath returns a new path with elem added to the end, leaving path
+// untouched
+func appendPath(path []string, elem string) []string {
+	return append(append([]string{}, path...), elem)
+}
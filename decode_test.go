@@ -0,0 +1,230 @@
+package yrm
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	check "gitlab.com/MaxIV/lib-maxiv-go-check"
+)
+
+type testPorts struct {
+	HTTP int   `yrm:"http"`
+	GRPC *int  `yrm:"grpc,omitempty"`
+	Rest []int `yrm:"rest"`
+}
+
+type testServer struct {
+	Host string
+	Port uint16 `yrm:"port"`
+}
+
+type testConfig struct {
+	Host         string                 `yrm:"host"`
+	Ports        testPorts              `yrm:"ports"`
+	StartupDelay float64                `yrm:"startup_delay"`
+	Verbose      bool                   `yrm:"verbose"`
+	Servers      []*testServer          `yrm:"servers"`
+	Limits       map[string]float32     `yrm:"limits"`
+	Extra        map[string]interface{} `yrm:"extra"`
+	Anything     interface{}            `yrm:"anything"`
+	Secret       string                 `yrm:"-"`
+	Pair         [2]string              `yrm:"pair"`
+}
+
+var decodeInput = `
+host: "localhost"
+ports:
+	http: 8888
+	grpc: 9999
+	rest: [1, 2, 3]
+startup_delay: 5
+verbose: true
+servers:
+	- {host: "alpha", port: 80}
+	-
+		host: "beta"
+		port: 443
+limits: {cpu: 1.5, memory: 512}
+extra:
+	a: 1
+anything: [true, "b"]
+Secret: "should not be set"
+pair: ["a", "b"]
+unknown: 5
+`
+
+func TestUnmarshal(t *testing.T) {
+	var c testConfig
+	c.Secret = "untouched"
+
+	err := Unmarshal([]byte(decodeInput), &c)
+	check.OK(t, err)
+
+	grpc := 9999
+	exp := testConfig{
+		Host: "localhost",
+		Ports: testPorts{
+			HTTP: 8888,
+			GRPC: &grpc,
+			Rest: []int{1, 2, 3},
+		},
+		StartupDelay: 5,
+		Verbose:      true,
+		Servers: []*testServer{
+			&testServer{Host: "alpha", Port: 80},
+			&testServer{Host: "beta", Port: 443},
+		},
+		Limits:   map[string]float32{"cpu": 1.5, "memory": 512},
+		Extra:    map[string]interface{}{"a": 1},
+		Anything: []interface{}{true, "b"},
+		Secret:   "untouched",
+		Pair:     [2]string{"a", "b"},
+	}
+	check.Equals(t, exp, c)
+}
+
+func TestUnmarshalInterface(t *testing.T) {
+	var v interface{}
+	err := Unmarshal([]byte("a: 1\n"), &v)
+	check.OK(t, err)
+	check.Equals(t, map[string]interface{}{"a": 1}, v)
+}
+
+func TestUnmarshalNull(t *testing.T) {
+	grpc := 9999
+	c := testConfig{
+		Host:     "localhost",
+		Ports:    testPorts{HTTP: 80, GRPC: &grpc},
+		Servers:  []*testServer{&testServer{Host: "alpha"}},
+		Anything: 5,
+	}
+
+	input := "host: null\nports:\n\tgrpc: null\nservers: [null]\nanything: null\n"
+	err := Unmarshal([]byte(input), &c)
+	check.OK(t, err)
+
+	check.Equals(t, "", c.Host)
+	check.Equals(t, 80, c.Ports.HTTP)
+	check.Assert(t, c.Ports.GRPC == nil)
+	check.Equals(t, []*testServer{nil}, c.Servers)
+	check.Equals(t, nil, c.Anything)
+
+	// a whole struct is cleared too
+	err = Unmarshal([]byte("ports: null\n"), &c)
+	check.OK(t, err)
+	check.Equals(t, testPorts{}, c.Ports)
+}
+
+func TestUnmarshalUnits(t *testing.T) {
+	var v struct {
+		Timeout time.Duration `yrm:"timeout"`
+		Buffer  ByteSize      `yrm:"buffer"`
+		Limit   int64         `yrm:"limit"`
+	}
+
+	err := Unmarshal([]byte("timeout: 1h30m\nbuffer: 512KiB\nlimit: 2GB\n"), &v)
+	check.OK(t, err)
+	check.Equals(t, 90*time.Minute, v.Timeout)
+	check.Equals(t, ByteSize(512*1024), v.Buffer)
+	check.Equals(t, int64(2e9), v.Limit)
+
+	b, err := Marshal(&v)
+	check.OK(t, err)
+	check.Equals(t, "timeout: 1h30m0s\nbuffer: 512KiB\nlimit: 2000000000\n", string(b))
+
+	err = Unmarshal([]byte("limit: 30s\n"), &v)
+	check.NotOK(t, err)
+	check.Equals(t, "yrm: cannot unmarshal duration into Go value of type int64 at 'limit'", err.Error())
+
+	// zero values are written as 0s and 0B, which must read back
+	zero := v
+	zero.Timeout, zero.Buffer = 0, 0
+	b, err = Marshal(&zero)
+	check.OK(t, err)
+	check.Equals(t, "timeout: 0s\nbuffer: 0B\nlimit: 2000000000\n", string(b))
+	err = Unmarshal(b, &v)
+	check.OK(t, err)
+	check.Equals(t, zero, v)
+
+	m, err := Parse("a: 00s\nb: 0755s\n")
+	check.OK(t, err)
+	check.Equals(t, map[string]interface{}{"a": time.Duration(0), "b": 755 * time.Second}, m)
+
+	err = Unmarshal([]byte("timeout: 30\n"), &v)
+	check.NotOK(t, err)
+	check.Equals(t, "yrm: cannot unmarshal int into Go value of type time.Duration at 'timeout'", err.Error())
+
+	var typeErr *UnmarshalTypeError
+	check.Assert(t, errors.As(err, &typeErr))
+}
+
+func TestUnmarshalDates(t *testing.T) {
+	var v struct {
+		Start  time.Time  `yrm:"start"`
+		Day    Date       `yrm:"day"`
+		Expiry *time.Time `yrm:"expiry"`
+	}
+
+	err := Unmarshal([]byte("start: 2024-01-15T10:30:00Z\nday: 2024-01-15\nexpiry: 2025-06-30\n"), &v)
+	check.OK(t, err)
+	check.Equals(t, time.Date(2024, time.January, 15, 10, 30, 0, 0, time.UTC), v.Start)
+	check.Equals(t, Date{Year: 2024, Month: time.January, Day: 15}, v.Day)
+	check.Equals(t, time.Date(2025, time.June, 30, 0, 0, 0, 0, time.UTC), *v.Expiry)
+
+	b, err := Marshal(&v)
+	check.OK(t, err)
+	check.Equals(t, "start: 2024-01-15T10:30:00Z\nday: 2024-01-15\nexpiry: 2025-06-30T00:00:00Z\n", string(b))
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	type row struct {
+		Input string
+		Error string
+	}
+
+	table := []row{
+		row{
+			Input: "ports:\n\thttp: \"80\"\n",
+			Error: "yrm: cannot unmarshal string into Go value of type int at 'ports.http'",
+		},
+		row{
+			Input: "servers:\n\t-\n\t\thost: \"a\"\n\t\tport: 70000\n",
+			Error: "yrm: cannot unmarshal int into Go value of type uint16 at 'servers.0.port'",
+		},
+		row{
+			Input: "ports: 5\n",
+			Error: "yrm: cannot unmarshal int into Go value of type yrm.testPorts at 'ports'",
+		},
+		row{
+			Input: "pair: [\"a\"]\n",
+			Error: "yrm: cannot unmarshal list into Go value of type [2]string at 'pair'",
+		},
+		row{
+			Input: "verbose: 1.5\n",
+			Error: "yrm: cannot unmarshal float into Go value of type bool at 'verbose'",
+		},
+	}
+
+	for i, r := range table {
+		var c testConfig
+		err := Unmarshal([]byte(r.Input), &c)
+		check.NotOKWithMessage(t, err, "row: %d", i+1)
+		check.EqualsWithMessage(t, r.Error, err.Error(), "row: %d", i+1)
+
+		var typeErr *UnmarshalTypeError
+		check.AssertWithMessage(t, errors.As(err, &typeErr), "row: %d", i+1)
+	}
+
+	var c testConfig
+	err := Unmarshal([]byte("a: 1\n"), c)
+	check.Equals(t, "yrm: Unmarshal(non-pointer yrm.testConfig)", err.Error())
+
+	err = Unmarshal([]byte("a: 1\n"), nil)
+	check.Equals(t, "yrm: Unmarshal(nil)", err.Error())
+
+	// syntax errors are passed on
+	var syntax *SyntaxError
+	err = Unmarshal([]byte("a: [1\n"), &c)
+	check.AssertWithMessage(t, errors.As(err, &syntax), "expected *SyntaxError, got %v", err)
+}
@@ -0,0 +1,574 @@
+package yrm
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/doctordesh/yrm/ast"
+)
+
+// Document is a parsed document that can be edited in place. An edit only
+// rewrites the lines of the values it changes, everything else (comments,
+// blank lines and the order of keys) is kept byte for byte.
+//
+// Paths are keys separated by dots, with list items given by their index,
+// e.g. "servers.0.host".
+type Document struct {
+	src    []byte
+	file   *ast.File
+	indent string // one level of indentation, a tab unless the input uses spaces
+}
+
+// ParseDocument parses the input into an editable document
+func ParseDocument(src []byte) (*Document, error) {
+	file, err := parseAST("", bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{src: append([]byte{}, src...), file: file}
+	doc.indent = doc.indentUnit()
+	return doc, nil
+}
+
+// Bytes returns the document, with all edits made so far
+func (self *Document) Bytes() []byte {
+	return self.src
+}
+
+// AST returns the syntax tree of the document as it is after the edits
+func (self *Document) AST() *ast.File {
+	return self.file
+}
+
+// Get returns the value at the path, see Parse for the types of values
+func (self *Document) Get(path string) (interface{}, error) {
+	node, err := self.lookup(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ast.Value(node), nil
+}
+
+// GetJSON returns the value at the path as indented JSON, written the same
+// way as by ToJSON
+func (self *Document) GetJSON(path string) ([]byte, error) {
+	node, err := self.lookup(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return indentJSON(node)
+}
+
+// lookup returns the node of the value at the path
+func (self *Document) lookup(path string) (ast.Node, error) {
+	steps, err := self.walk(SplitPath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	last := steps[len(steps)-1]
+	if last.found() == false {
+		return nil, fmt.Errorf("yrm: '%s' not found", path)
+	}
+
+	return last.value(), nil
+}
+
+// Set sets the value at the path. A key that doesn't exist is added, see
+// Insert.
+func (self *Document) Set(path string, value interface{}) error {
+	return self.edit(SplitPath(path), opSet, value)
+}
+
+// Insert adds a key to the end of its map, creating the maps leading up to
+// it if they don't exist. In a list, the value is inserted before the item
+// at the index, or appended if the index is the length of the list. It's an
+// error to insert a key that already exists.
+func (self *Document) Insert(path string, value interface{}) error {
+	return self.edit(SplitPath(path), opInsert, value)
+}
+
+// Delete removes the key or list item at the path, together with the
+// comments right above it
+func (self *Document) Delete(path string) error {
+	return self.edit(SplitPath(path), opDelete, nil)
+}
+
+type operation int
+
+const (
+	opSet operation = iota
+	opInsert
+	opDelete
+)
+
+// step is one element of a path, taken in a map or a list
+type step struct {
+	node  ast.Node      // *ast.MapNode or *ast.ListNode
+	depth int           // number of tabs in front of the entries of node
+	entry *ast.KeyValue // the entry for the key, if node is a map
+	item  *ast.ListItem // the item at the index, if node is a list
+	index int
+}
+
+// found reports whether the key or index exists
+func (self step) found() bool {
+	return self.entry != nil || self.item != nil
+}
+
+// value returns the value at the key or index, which must exist
+func (self step) value() ast.Node {
+	if self.entry != nil {
+		return self.entry.Value
+	}
+	return self.item.Value
+}
+
+// flow reports whether the step is taken in a flow collection
+func (self step) flow() bool {
+	switch n := self.node.(type) {
+	case *ast.MapNode:
+		return n.Flow()
+	case *ast.ListNode:
+		return n.Flow()
+	}
+	return false
+}
+
+// walk follows the path as far as it exists. The last step returned is
+// either the last element of the path or the first one that doesn't exist.
+func (self *Document) walk(keys []string) ([]step, error) {
+	var steps []step
+
+	var node ast.Node = self.file.Map
+	depth := 0
+
+	for i, key := range keys {
+		s := step{node: node, depth: depth}
+
+		switch n := node.(type) {
+		case *ast.MapNode:
+			s.entry = n.Lookup(key)
+		case *ast.ListNode:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("yrm: '%s' is not a list index", strings.Join(keys[:i+1], "."))
+			}
+
+			s.index = index
+			if index < len(n.Items) {
+				s.item = n.Items[index]
+			}
+		default:
+			return nil, fmt.Errorf("yrm: '%s' is not a map or a list", strings.Join(keys[:i], "."))
+		}
+
+		steps = append(steps, s)
+		if s.found() == false {
+			break
+		}
+
+		node = s.value()
+		depth += 1
+	}
+
+	return steps, nil
+}
+
+// edit carries out the operation and parses the result
+func (self *Document) edit(keys []string, op operation, value interface{}) error {
+	steps, err := self.walk(keys)
+	if err != nil {
+		return err
+	}
+
+	// Flow collections are written anew as a whole
+	for i := range steps {
+		if steps[i].flow() {
+			return self.editFlow(steps[i].node, keys[i:], op, value)
+		}
+	}
+
+	last := steps[len(steps)-1]
+	path := strings.Join(keys, ".")
+
+	switch {
+	case last.found() && op == opSet:
+		return self.replaceValue(last, value)
+	case last.found() && op == opDelete:
+		return self.deleteStep(steps)
+	case last.found() && last.entry != nil:
+		return fmt.Errorf("yrm: '%s' already exists", path)
+	case last.found():
+		return self.insertItem(last, value)
+	case op == opDelete:
+		return fmt.Errorf("yrm: '%s' not found", path)
+	}
+
+	// The path exists up to the last step, anything after it is created
+	missing := len(steps) - 1
+	v, err := editValue(nil, keys[missing+1:], opSet, value)
+	if err != nil {
+		return err
+	}
+
+	if list, ok := last.node.(*ast.ListNode); ok {
+		if last.index != len(list.Items) {
+			return fmt.Errorf("yrm: index '%s' out of range", strings.Join(keys[:missing+1], "."))
+		}
+		return self.insertItem(last, v)
+	}
+
+	return self.insertEntry(last, keys[missing], v)
+}
+
+// editFlow makes the edit to the value of the flow collection and writes it
+// anew
+func (self *Document) editFlow(node ast.Node, keys []string, op operation, value interface{}) error {
+	v, err := editValue(orderedValue(node), keys, op, value)
+	if err != nil {
+		return err
+	}
+
+	e := &encoder{}
+	err = e.encodeFlow(nil, reflect.ValueOf(v))
+	if err != nil {
+		return err
+	}
+
+	return self.splice(node.Pos().Offset, node.End(), e.buf.String())
+}
+
+// replaceValue replaces the value of an existing key or list item
+func (self *Document) replaceValue(s step, value interface{}) error {
+	text, err := self.nested(value, s.depth)
+	if err != nil {
+		return err
+	}
+
+	var start int
+	if s.entry != nil {
+		start = s.entry.Colon.End
+	} else {
+		start = s.item.Dash.End
+	}
+
+	return self.splice(start, s.value().End(), text)
+}
+
+// deleteStep removes the key or list item of the last step, including the
+// lines it takes up and the comments above it
+func (self *Document) deleteStep(steps []step) error {
+	last := steps[len(steps)-1]
+
+	// A nested block can't be empty, it's replaced by '{}' or '[]'
+	if len(steps) > 1 {
+		empty := false
+		var replacement interface{}
+		switch n := last.node.(type) {
+		case *ast.MapNode:
+			empty, replacement = len(n.Entries) == 1, map[string]interface{}{}
+		case *ast.ListNode:
+			empty, replacement = len(n.Items) == 1, []interface{}{}
+		}
+
+		if empty {
+			return self.replaceValue(steps[len(steps)-2], replacement)
+		}
+	}
+
+	var node ast.Node
+	var doc *ast.CommentGroup
+	if last.entry != nil {
+		node, doc = last.entry, last.entry.Doc
+	} else {
+		node, doc = last.item, last.item.Doc
+	}
+
+	start := node.Pos().Offset
+	if doc != nil {
+		start = doc.Pos().Offset
+	}
+
+	end := self.lineEnd(node.End())
+	if end < len(self.src) {
+		end += 1
+	}
+
+	return self.splice(self.lineStart(start), end, "")
+}
+
+// insertEntry adds the key to the end of the block map of the step
+func (self *Document) insertEntry(s step, key string, value interface{}) error {
+	quoted, err := quoteKey(nil, key)
+	if err != nil {
+		return fmt.Errorf("yrm: %q cannot be used as a key", key)
+	}
+
+	text, err := self.nested(value, s.depth)
+	if err != nil {
+		return err
+	}
+
+	line := strings.Repeat(self.indent, s.depth) + quoted + ":" + text
+
+	m := s.node.(*ast.MapNode)
+	if len(m.Entries) == 0 {
+		// only the top level map can be an empty block
+		prefix := ""
+		if len(self.src) > 0 && self.src[len(self.src)-1] != '\n' {
+			prefix = "\n"
+		}
+
+		return self.splice(len(self.src), len(self.src), prefix+line+"\n")
+	}
+
+	at := self.lineEnd(m.End())
+	return self.splice(at, at, "\n"+line)
+}
+
+// insertItem inserts the value before the item of the step in a block list,
+// or appends it if the step is past the last item
+func (self *Document) insertItem(s step, value interface{}) error {
+	text, err := self.nested(value, s.depth)
+	if err != nil {
+		return err
+	}
+
+	line := strings.Repeat(self.indent, s.depth) + "-" + text
+
+	if s.item == nil {
+		at := self.lineEnd(s.node.End())
+		return self.splice(at, at, "\n"+line)
+	}
+
+	start := s.item.Pos().Offset
+	if s.item.Doc != nil {
+		start = s.item.Doc.Pos().Offset
+	}
+
+	at := self.lineStart(start)
+	return self.splice(at, at, line+"\n")
+}
+
+// splice replaces the input between start and end with the text, and parses
+// the result. The document is left untouched if the result is invalid.
+func (self *Document) splice(start, end int, text string) error {
+	src := make([]byte, 0, len(self.src)-(end-start)+len(text))
+	src = append(src, self.src[:start]...)
+	src = append(src, text...)
+	src = append(src, self.src[end:]...)
+
+	file, err := parseAST("", bytes.NewReader(src))
+	if err != nil {
+		return fmt.Errorf("yrm: edit gives an invalid document: %w", err)
+	}
+
+	self.src = src
+	self.file = file
+	return nil
+}
+
+// lineStart returns the offset of the first byte on the line of offset
+func (self *Document) lineStart(offset int) int {
+	return bytes.LastIndexByte(self.src[:offset], '\n') + 1
+}
+
+// lineEnd returns the offset of the new line that ends the line of offset,
+// or the end of the input
+func (self *Document) lineEnd(offset int) int {
+	i := bytes.IndexByte(self.src[offset:], '\n')
+	if i < 0 {
+		return len(self.src)
+	}
+	return offset + i
+}
+
+// nested returns the text that follows 'key:' or '-' for the value, without
+// the final new line, indented like the rest of the document
+func (self *Document) nested(value interface{}, depth int) (string, error) {
+	e := &encoder{}
+	err := e.encodeNested(nil, reflect.ValueOf(value), depth)
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(strings.TrimSuffix(e.buf.String(), "\n"), "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, "\t")
+		lines[i] = strings.Repeat(self.indent, len(line)-len(trimmed)) + trimmed
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// indentUnit returns the whitespace in front of the first indented key or
+// list item, or a tab if there is none
+func (self *Document) indentUnit() string {
+	for _, e := range self.file.Map.Entries {
+		var first ast.Node
+		switch n := e.Value.(type) {
+		case *ast.MapNode:
+			if n.Flow() == false && len(n.Entries) > 0 {
+				first = n.Entries[0]
+			}
+		case *ast.ListNode:
+			if n.Flow() == false && len(n.Items) > 0 {
+				first = n.Items[0]
+			}
+		}
+
+		if first != nil {
+			offset := first.Pos().Offset
+			return string(self.src[self.lineStart(offset):offset])
+		}
+	}
+
+	return "\t"
+}
+
+// orderedValue returns the value of the node like ast.Value does, but with
+// maps as orderedMap so that they keep the order of the source
+func orderedValue(node ast.Node) interface{} {
+	switch n := node.(type) {
+	case *ast.MapNode:
+		m := orderedMap{}
+		for _, e := range n.Entries {
+			m = append(m, entry{key: e.Key.Literal, value: reflect.ValueOf(orderedValue(e.Value))})
+		}
+		return m
+	case *ast.ListNode:
+		list := []interface{}{}
+		for _, item := range n.Items {
+			list = append(list, orderedValue(item.Value))
+		}
+		return list
+	}
+
+	return ast.Value(node)
+}
+
+// editValue makes the edit to a parsed value, and returns the result. Maps
+// that are missing on the way are created.
+func editValue(v interface{}, keys []string, op operation, value interface{}) (interface{}, error) {
+	if len(keys) == 0 {
+		return value, nil
+	}
+
+	switch c := v.(type) {
+	case nil:
+		if op == opDelete {
+			return nil, fmt.Errorf("yrm: '%s' not found", keys[0])
+		}
+		return editValue(map[string]interface{}{}, keys, op, value)
+	case map[string]interface{}:
+		child, ok := c[keys[0]]
+		switch {
+		case len(keys) > 1:
+			sub, err := editValue(child, keys[1:], op, value)
+			if err != nil {
+				return nil, err
+			}
+			c[keys[0]] = sub
+		case op == opDelete && ok == false:
+			return nil, fmt.Errorf("yrm: '%s' not found", keys[0])
+		case op == opDelete:
+			delete(c, keys[0])
+		case op == opInsert && ok:
+			return nil, fmt.Errorf("yrm: '%s' already exists", keys[0])
+		default:
+			c[keys[0]] = value
+		}
+		return c, nil
+	case orderedMap:
+		i := c.index(keys[0])
+		switch {
+		case len(keys) > 1:
+			var child interface{}
+			if i >= 0 && c[i].value.IsValid() {
+				child = c[i].value.Interface()
+			}
+			sub, err := editValue(child, keys[1:], op, value)
+			if err != nil {
+				return nil, err
+			}
+			if i < 0 {
+				return append(c, entry{key: keys[0], value: reflect.ValueOf(sub)}), nil
+			}
+			c[i].value = reflect.ValueOf(sub)
+		case op == opDelete && i < 0:
+			return nil, fmt.Errorf("yrm: '%s' not found", keys[0])
+		case op == opDelete:
+			c = append(c[:i], c[i+1:]...)
+		case op == opInsert && i >= 0:
+			return nil, fmt.Errorf("yrm: '%s' already exists", keys[0])
+		case i < 0:
+			c = append(c, entry{key: keys[0], value: reflect.ValueOf(value)})
+		default:
+			c[i].value = reflect.ValueOf(value)
+		}
+		return c, nil
+	case []interface{}:
+		index, err := strconv.Atoi(keys[0])
+		if err != nil || index < 0 || index > len(c) || (index == len(c) && op != opInsert && len(keys) == 1) {
+			return nil, fmt.Errorf("yrm: index '%s' out of range", keys[0])
+		}
+
+		switch {
+		case len(keys) > 1:
+			if index == len(c) {
+				return nil, fmt.Errorf("yrm: index '%s' out of range", keys[0])
+			}
+			sub, err := editValue(c[index], keys[1:], op, value)
+			if err != nil {
+				return nil, err
+			}
+			c[index] = sub
+		case op == opDelete:
+			c = append(c[:index], c[index+1:]...)
+		case op == opInsert:
+			c = append(c[:index], append([]interface{}{value}, c[index:]...)...)
+		default:
+			c[index] = value
+		}
+		return c, nil
+	}
+
+	return nil, fmt.Errorf("yrm: '%s' is not in a map or a list", keys[0])
+}
+
+// SplitPath splits a dotted path, as taken by Config and Document, into its
+// keys. A key with dots in it is written in double quotes, with '\"' and
+// '\\' for quotes and backslashes, e.g. 'hosts."example.com".port'.
+func SplitPath(path string) []string {
+	var keys []string
+	var key strings.Builder
+	quoted := false
+
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+
+		switch {
+		case quoted && c == '\\' && i+1 < len(path):
+			i += 1
+			key.WriteByte(path[i])
+		case quoted && c == '"':
+			quoted = false
+		case quoted == false && c == '"' && key.Len() == 0:
+			quoted = true
+		case quoted == false && c == '.':
+			keys = append(keys, key.String())
+			key.Reset()
+		default:
+			key.WriteByte(c)
+		}
+	}
+
+	return append(keys, key.String())
+}
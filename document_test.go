@@ -0,0 +1,325 @@
+package yrm
+
+import (
+	"testing"
+
+	check "gitlab.com/MaxIV/lib-maxiv-go-check"
+)
+
+var documentInput = `// The host
+host: "localhost"
+
+ports:
+	// the main port
+	http: 8888
+	grpc: 9999
+servers:
+	- {name: "a", tags: [1, 2]}
+	-
+		name: "b"
+// trailing
+`
+
+func TestDocumentUnchanged(t *testing.T) {
+	doc, err := ParseDocument([]byte(documentInput))
+	check.OK(t, err)
+	check.Equals(t, documentInput, string(doc.Bytes()))
+
+	v, err := doc.Get("servers.0.tags.1")
+	check.OK(t, err)
+	check.Equals(t, 2, v)
+
+	_, err = doc.Get("ports.rest")
+	check.NotOK(t, err)
+}
+
+func TestDocumentEdit(t *testing.T) {
+	type row struct {
+		edit func(doc *Document) error
+		exp  string
+	}
+
+	table := []row{
+		{
+			func(doc *Document) error { return doc.Set("ports.http", 80) },
+			`// The host
+host: "localhost"
+
+ports:
+	// the main port
+	http: 80
+	grpc: 9999
+servers:
+	- {name: "a", tags: [1, 2]}
+	-
+		name: "b"
+// trailing
+`,
+		},
+		{
+			func(doc *Document) error { return doc.Set("host", map[string]int{"port": 1}) },
+			`// The host
+host:
+	port: 1
+
+ports:
+	// the main port
+	http: 8888
+	grpc: 9999
+servers:
+	- {name: "a", tags: [1, 2]}
+	-
+		name: "b"
+// trailing
+`,
+		},
+		{
+			func(doc *Document) error { return doc.Delete("ports.http") },
+			`// The host
+host: "localhost"
+
+ports:
+	grpc: 9999
+servers:
+	- {name: "a", tags: [1, 2]}
+	-
+		name: "b"
+// trailing
+`,
+		},
+		{
+			func(doc *Document) error { return doc.Delete("servers.1.name") },
+			`// The host
+host: "localhost"
+
+ports:
+	// the main port
+	http: 8888
+	grpc: 9999
+servers:
+	- {name: "a", tags: [1, 2]}
+	- {}
+// trailing
+`,
+		},
+		{
+			func(doc *Document) error { return doc.Insert("ports.https", 443) },
+			`// The host
+host: "localhost"
+
+ports:
+	// the main port
+	http: 8888
+	grpc: 9999
+	https: 443
+servers:
+	- {name: "a", tags: [1, 2]}
+	-
+		name: "b"
+// trailing
+`,
+		},
+		{
+			func(doc *Document) error { return doc.Set("limits.memory", 512) },
+			`// The host
+host: "localhost"
+
+ports:
+	// the main port
+	http: 8888
+	grpc: 9999
+servers:
+	- {name: "a", tags: [1, 2]}
+	-
+		name: "b"
+limits:
+	memory: 512
+// trailing
+`,
+		},
+		{
+			func(doc *Document) error { return doc.Insert("servers.0", "first") },
+			`// The host
+host: "localhost"
+
+ports:
+	// the main port
+	http: 8888
+	grpc: 9999
+servers:
+	- "first"
+	- {name: "a", tags: [1, 2]}
+	-
+		name: "b"
+// trailing
+`,
+		},
+		{
+			func(doc *Document) error { return doc.Insert("servers.2", "last") },
+			`// The host
+host: "localhost"
+
+ports:
+	// the main port
+	http: 8888
+	grpc: 9999
+servers:
+	- {name: "a", tags: [1, 2]}
+	-
+		name: "b"
+	- "last"
+// trailing
+`,
+		},
+		{
+			func(doc *Document) error { return doc.Insert("servers.0.tags.2", 3) },
+			`// The host
+host: "localhost"
+
+ports:
+	// the main port
+	http: 8888
+	grpc: 9999
+servers:
+	- {name: "a", tags: [1, 2, 3]}
+	-
+		name: "b"
+// trailing
+`,
+		},
+		{
+			func(doc *Document) error { return doc.Set("servers.0.id", 7) },
+			`// The host
+host: "localhost"
+
+ports:
+	// the main port
+	http: 8888
+	grpc: 9999
+servers:
+	- {name: "a", tags: [1, 2], id: 7}
+	-
+		name: "b"
+// trailing
+`,
+		},
+		{
+			func(doc *Document) error { return doc.Delete("servers.0.name") },
+			`// The host
+host: "localhost"
+
+ports:
+	// the main port
+	http: 8888
+	grpc: 9999
+servers:
+	- {tags: [1, 2]}
+	-
+		name: "b"
+// trailing
+`,
+		},
+		{
+			func(doc *Document) error { return doc.Delete("host") },
+			`
+ports:
+	// the main port
+	http: 8888
+	grpc: 9999
+servers:
+	- {name: "a", tags: [1, 2]}
+	-
+		name: "b"
+// trailing
+`,
+		},
+	}
+
+	for i, r := range table {
+		doc, err := ParseDocument([]byte(documentInput))
+		check.OK(t, err)
+
+		err = r.edit(doc)
+		check.OK(t, err)
+		check.EqualsWithMessage(t, r.exp, string(doc.Bytes()), "row %d", i)
+	}
+}
+
+func TestDocumentEmpty(t *testing.T) {
+	doc, err := ParseDocument([]byte("// nothing yet\n"))
+	check.OK(t, err)
+
+	err = doc.Set("a.b", 1)
+	check.OK(t, err)
+	check.Equals(t, "// nothing yet\na:\n\tb: 1\n", string(doc.Bytes()))
+}
+
+func TestDocumentGetJSON(t *testing.T) {
+	doc, err := ParseDocument([]byte("a:\n\tz: 2024-01-15\n\tt: 10:30:00\n\td: [1h30m, 1.0]\n"))
+	check.OK(t, err)
+
+	b, err := doc.GetJSON("a")
+	check.OK(t, err)
+
+	exp := `{
+    "z": "2024-01-15",
+    "t": "10:30:00",
+    "d": [
+        "1h30m0s",
+        1.0
+    ]
+}
+`
+	check.Equals(t, exp, string(b))
+
+	_, err = doc.GetJSON("b")
+	check.NotOK(t, err)
+}
+
+func TestSplitPath(t *testing.T) {
+	check.Equals(t, []string{"a", "b"}, SplitPath("a.b"))
+	check.Equals(t, []string{"a", "b.c", "d"}, SplitPath(`a."b.c".d`))
+	check.Equals(t, []string{`x"y\z`, ""}, SplitPath(`"x\"y\\z".`))
+
+	doc, err := ParseDocument([]byte("a: 1\n"))
+	check.OK(t, err)
+
+	err = doc.Set(`"b.c"`, 2)
+	check.OK(t, err)
+	check.Equals(t, "a: 1\n\"b.c\": 2\n", string(doc.Bytes()))
+}
+
+func TestDocumentErrors(t *testing.T) {
+	doc, err := ParseDocument([]byte(documentInput))
+	check.OK(t, err)
+
+	paths := []string{
+		"host.a",       // not a map
+		"servers.x",    // not an index
+		"servers.5",    // out of range
+		"ports.nope.a", // delete of missing key
+	}
+
+	for _, p := range paths {
+		err = doc.Delete(p)
+		check.NotOK(t, err)
+	}
+
+	err = doc.Insert("ports.http", 1)
+	check.NotOK(t, err)
+
+	err = doc.Set("ports.a\xffb", 1)
+	check.NotOK(t, err)
+
+	// failed edits leave the document as it was
+	check.Equals(t, documentInput, string(doc.Bytes()))
+}
+
+func TestDocumentSpaces(t *testing.T) {
+	doc, err := ParseDocument([]byte("ports:\n  http: 80\n"))
+	check.OK(t, err)
+
+	err = doc.Set("ports.limits", map[string]int{"cpu": 2})
+	check.OK(t, err)
+	check.Equals(t, "ports:\n  http: 80\n  limits:\n    cpu: 2\n", string(doc.Bytes()))
+}
@@ -0,0 +1,528 @@
+package yrm
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// UnsupportedTypeError is returned by Marshal when a value has a type that
+// cannot be represented
+type UnsupportedTypeError struct {
+	Path []string
+	Type reflect.Type
+}
+
+func (self *UnsupportedTypeError) Error() string {
+	return fmt.Sprintf("yrm: unsupported type: %s at '%s'", self.Type, strings.Join(self.Path, "."))
+}
+
+// UnsupportedValueError is returned by Marshal when a value has a supported
+// type, but the value itself cannot be represented
+type UnsupportedValueError struct {
+	Path  []string
+	Value reflect.Value
+	Str   string
+}
+
+func (self *UnsupportedValueError) Error() string {
+	return fmt.Sprintf("yrm: unsupported value: %s at '%s'", self.Str, strings.Join(self.Path, "."))
+}
+
+// Marshal returns the YRM encoding of v, which must be a map with string keys
+// or a struct (or a pointer to one of them).
+//
+// Nested maps, structs and lists are written as blocks indented with tabs,
+// empty ones as '{}' and '[]'. Map keys are sorted, struct fields are written
+// in the order they are declared and named as described for Unmarshal. A
+// field with the 'omitempty' option is left out if it has an empty value.
+// Nil pointers and interfaces are written as null.
+func Marshal(v interface{}) ([]byte, error) {
+	e := &encoder{}
+
+	rv := indirect(reflect.ValueOf(v))
+	switch rv.Kind() {
+	case reflect.Map, reflect.Struct:
+	default:
+		return nil, &UnsupportedTypeError{Type: reflect.TypeOf(v)}
+	}
+
+	entries, err := e.entries(nil, rv)
+	if err != nil {
+		return nil, err
+	}
+
+	err = e.encodeEntries(nil, entries, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.buf.Bytes(), nil
+}
+
+// MarshalScalar returns a single string, number, bool, duration, byte size,
+// date or time as it is written in YRM, e.g. '1.0' or '10:30:00'. Nil is
+// written as null.
+func MarshalScalar(v interface{}) (string, error) {
+	return scalar(nil, reflect.ValueOf(v))
+}
+
+type encoder struct {
+	buf bytes.Buffer
+}
+
+// entry is a key and its value in a map or struct that is being encoded
+type entry struct {
+	key     string
+	value   reflect.Value
+	comment []string // lines of comments written above the key
+}
+
+// orderedMap is a map that is written in the order of its entries, used
+// when converting from formats that have an order
+type orderedMap []entry
+
+// index returns the index of the entry with the key, or -1 if there is none
+func (self orderedMap) index(key string) int {
+	for i, e := range self {
+		if e.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// commented is a list item with comments above it, used when converting from
+// formats that have comments
+type commented struct {
+	comment []string
+	value   interface{}
+}
+
+var orderedMapType = reflect.TypeOf(orderedMap{})
+var commentedType = reflect.TypeOf(commented{})
+var durationType = reflect.TypeOf(time.Duration(0))
+var byteSizeType = reflect.TypeOf(ByteSize(0))
+var timeType = reflect.TypeOf(time.Time{})
+var dateType = reflect.TypeOf(Date{})
+
+// entries returns the entries of the map or struct rv in the order they are
+// written
+func (self *encoder) entries(path []string, rv reflect.Value) ([]entry, error) {
+	var entries []entry
+
+	if rv.Type() == orderedMapType {
+		return rv.Interface().(orderedMap), nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, &UnsupportedTypeError{Path: path, Type: rv.Type()}
+		}
+
+		for _, key := range rv.MapKeys() {
+			entries = append(entries, entry{key: key.String(), value: rv.MapIndex(key)})
+		}
+
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].key < entries[j].key
+		})
+	case reflect.Struct:
+		for _, f := range structFields(rv.Type()) {
+			value := rv.Field(f.index)
+			if f.omitEmpty && isEmptyValue(value) {
+				continue
+			}
+
+			entries = append(entries, entry{key: f.name, value: value})
+		}
+	}
+
+	return entries, nil
+}
+
+// encodeEntries writes one line per entry, and a block for each nested map,
+// struct or list
+func (self *encoder) encodeEntries(path []string, entries []entry, depth int) error {
+	for _, e := range entries {
+		p := appendPath(path, e.key)
+
+		key, err := quoteKey(path, e.key)
+		if err != nil {
+			return err
+		}
+
+		self.comment(e.comment, depth)
+		self.indent(depth)
+		self.buf.WriteString(key)
+		self.buf.WriteString(":")
+
+		err = self.encodeNested(p, e.value, depth)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeList writes one '- item' line per element of the slice or array rv,
+// and a block for each nested map, struct or list
+func (self *encoder) encodeList(path []string, rv reflect.Value, depth int) error {
+	for i := 0; i < rv.Len(); i++ {
+		comment, item := uncomment(indirect(rv.Index(i)))
+
+		self.comment(comment, depth)
+		self.indent(depth)
+		self.buf.WriteString("-")
+
+		err := self.encodeNested(appendPath(path, strconv.Itoa(i)), item, depth)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeNested writes the rest of a line that has been started with a key
+// and colon or a dash. Non-empty maps, structs and lists are written as a
+// block on the lines that follow, one level further in than depth.
+func (self *encoder) encodeNested(path []string, rv reflect.Value, depth int) error {
+	_, rv = uncomment(indirect(rv))
+
+	switch kind(rv) {
+	case reflect.Map, reflect.Struct:
+		entries, err := self.entries(path, rv)
+		if err != nil {
+			return err
+		}
+
+		if len(entries) == 0 {
+			self.buf.WriteString(" {}\n")
+			return nil
+		}
+
+		self.buf.WriteString("\n")
+		return self.encodeEntries(path, entries, depth+1)
+	case reflect.Slice, reflect.Array:
+		if rv.Len() == 0 {
+			self.buf.WriteString(" []\n")
+			return nil
+		}
+
+		self.buf.WriteString("\n")
+		return self.encodeList(path, rv, depth+1)
+	}
+
+	s, err := scalar(path, rv)
+	if err != nil {
+		return err
+	}
+
+	self.buf.WriteString(" ")
+	self.buf.WriteString(s)
+	self.buf.WriteString("\n")
+	return nil
+}
+
+// encodeFlow writes the value on a single line, with maps as
+// '{key: value, ...}' and lists as '[value, ...]'
+func (self *encoder) encodeFlow(path []string, rv reflect.Value) error {
+	_, rv = uncomment(indirect(rv))
+
+	switch kind(rv) {
+	case reflect.Map, reflect.Struct:
+		entries, err := self.entries(path, rv)
+		if err != nil {
+			return err
+		}
+
+		self.buf.WriteString("{")
+		for i, e := range entries {
+			key, err := quoteKey(path, e.key)
+			if err != nil {
+				return err
+			}
+
+			if i > 0 {
+				self.buf.WriteString(", ")
+			}
+			self.buf.WriteString(key)
+			self.buf.WriteString(": ")
+
+			err = self.encodeFlow(appendPath(path, e.key), e.value)
+			if err != nil {
+				return err
+			}
+		}
+		self.buf.WriteString("}")
+		return nil
+	case reflect.Slice, reflect.Array:
+		self.buf.WriteString("[")
+		for i := 0; i < rv.Len(); i++ {
+			if i > 0 {
+				self.buf.WriteString(", ")
+			}
+
+			err := self.encodeFlow(appendPath(path, strconv.Itoa(i)), rv.Index(i))
+			if err != nil {
+				return err
+			}
+		}
+		self.buf.WriteString("]")
+		return nil
+	}
+
+	s, err := scalar(path, rv)
+	if err != nil {
+		return err
+	}
+
+	self.buf.WriteString(s)
+	return nil
+}
+
+// kind returns the kind of rv, with an orderedMap counting as a map. Times
+// and dates are structs, but are written as a single literal like strings.
+func kind(rv reflect.Value) reflect.Kind {
+	if rv.IsValid() && rv.Type() == orderedMapType {
+		return reflect.Map
+	}
+	if rv.IsValid() && (rv.Type() == timeType || rv.Type() == dateType) {
+		return reflect.String
+	}
+	return rv.Kind()
+}
+
+// uncomment returns the comments and value of a commented list item, or no
+// comments and rv itself for any other value
+func uncomment(rv reflect.Value) ([]string, reflect.Value) {
+	if rv.IsValid() && rv.Type() == commentedType {
+		c := rv.Interface().(commented)
+		return c.comment, indirect(reflect.ValueOf(c.value))
+	}
+	return nil, rv
+}
+
+// comment writes one comment line per line of text
+func (self *encoder) comment(lines []string, depth int) {
+	for _, line := range lines {
+		self.indent(depth)
+		self.buf.WriteString("//")
+		if line != "" {
+			self.buf.WriteString(" ")
+			self.buf.WriteString(line)
+		}
+		self.buf.WriteString("\n")
+	}
+}
+
+// indent writes one tab per level of depth
+func (self *encoder) indent(depth int) {
+	for i := 0; i < depth; i++ {
+		self.buf.WriteByte('\t')
+	}
+}
+
+// scalar returns the literal for a string, number or bool
+func scalar(path []string, rv reflect.Value) (string, error) {
+	switch rv.Kind() {
+	case reflect.String:
+		return quote(path, rv)
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch rv.Type() {
+		case durationType:
+			return time.Duration(rv.Int()).String(), nil
+		case byteSizeType:
+			return ByteSize(rv.Int()).String(), nil
+		}
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		switch {
+		case math.IsNaN(f):
+			return "nan", nil
+		case math.IsInf(f, 1):
+			return "inf", nil
+		case math.IsInf(f, -1):
+			return "-inf", nil
+		}
+
+		// A float must have a decimal point, or it would be read as an int
+		s := strconv.FormatFloat(f, 'f', -1, rv.Type().Bits())
+		if strings.Contains(s, ".") == false {
+			s += ".0"
+		}
+		return s, nil
+	case reflect.Struct:
+		switch rv.Type() {
+		case timeType:
+			return formatTime(rv.Interface().(time.Time)), nil
+		case dateType:
+			return rv.Interface().(Date).String(), nil
+		}
+	case reflect.Invalid:
+		return "null", nil
+	}
+
+	return "", &UnsupportedTypeError{Path: path, Type: rv.Type()}
+}
+
+// formatTime returns the time in RFC 3339 format, or as just the time of day
+// if it's on January 1st of year 0 in UTC, like the ones returned by Parse
+func formatTime(t time.Time) string {
+	if t.Year() == 0 && t.YearDay() == 1 && t.Location() == time.UTC {
+		return t.Format("15:04:05.999999999")
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// quote returns the string in double quotes, with quotes, backslashes and
+// control characters escaped. A string that isn't valid UTF-8 cannot be
+// written.
+func quote(path []string, rv reflect.Value) (string, error) {
+	s := rv.String()
+	if utf8.ValidString(s) == false {
+		return "", &UnsupportedValueError{Path: path, Value: rv, Str: strconv.Quote(s)}
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+
+	return b.String(), nil
+}
+
+// quoteMultiline returns the string in triple quotes, with its lines
+// indented one level more than depth
+func quoteMultiline(s string, depth int) string {
+	indent := strings.Repeat("\t", depth+1)
+
+	var b strings.Builder
+	b.WriteString("\"\"\"\n")
+	for _, line := range strings.Split(s, "\n") {
+		if line == "" {
+			b.WriteString("\n")
+			continue
+		}
+
+		b.WriteString(indent)
+		for j, r := range line {
+			switch {
+			case r == '\\':
+				b.WriteString(`\\`)
+			case r == '"' && strings.TrimLeft(line[:j], " \t") == "" && strings.HasPrefix(line[j:], `"""`):
+				// would be read as the closing quotes
+				b.WriteString(`\"`)
+			case r == '\t' && j == len(line)-1 && strings.TrimLeft(line, " \t") == "":
+				// a line of only whitespace would be read as empty
+				b.WriteString(`\t`)
+			case r == ' ' && j == len(line)-1 && strings.TrimLeft(line, " \t") == "":
+				b.WriteString(`\u0020`)
+			case r != '\t' && (r < 0x20 || r == 0x7f):
+				fmt.Fprintf(&b, `\u%04X`, r)
+			default:
+				b.WriteRune(r)
+			}
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(indent)
+	b.WriteString(`"""`)
+
+	return b.String()
+}
+
+// indirect follows pointers and interfaces down to the value they point at.
+// A nil pointer or interface gives the zero (invalid) Value.
+func indirect(rv reflect.Value) reflect.Value {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return reflect.Value{}
+		}
+		rv = rv.Elem()
+	}
+	return rv
+}
+
+// isIdentifier reports whether the key can be written as an identifier: a
+// letter or underscore, followed by letters, underscores, digits and dashes
+func isIdentifier(key string) bool {
+	if key == "" {
+		return false
+	}
+
+	for i, r := range key {
+		if 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || r == '_' {
+			continue
+		}
+		if i > 0 && ('0' <= r && r <= '9' || r == '-') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// quoteKey returns the key as it's written: bare if it's an identifier, and
+// in double quotes otherwise. A key that isn't valid UTF-8 cannot be written.
+func quoteKey(path []string, key string) (string, error) {
+	if isIdentifier(key) {
+		return key, nil
+	}
+
+	s, err := quote(path, reflect.ValueOf(key))
+	if err != nil {
+		return "", &UnsupportedValueError{Path: path, Value: reflect.ValueOf(key), Str: strconv.Quote(key) + " as key"}
+	}
+	return s, nil
+}
+
+// isEmptyValue reports whether the value is left out by 'omitempty'
+func isEmptyValue(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Bool:
+		return rv.Bool() == false
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return rv.IsNil()
+	}
+	return false
+}
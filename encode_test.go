@@ -0,0 +1,187 @@
+package yrm
+
+import (
+	"errors"
+	"testing"
+
+	check "gitlab.com/MaxIV/lib-maxiv-go-check"
+)
+
+func TestMarshal(t *testing.T) {
+	grpc := 9999
+	c := testConfig{
+		Host: "localhost",
+		Ports: testPorts{
+			HTTP: 8888,
+			GRPC: &grpc,
+			Rest: []int{1, 2},
+		},
+		StartupDelay: 5,
+		Servers: []*testServer{
+			&testServer{Host: "alpha", Port: 80},
+		},
+		Limits:   map[string]float32{"memory": 512, "cpu": 1.5},
+		Anything: []interface{}{[]int{1}, map[string]int{}},
+		Secret:   "hidden",
+		Pair:     [2]string{"a", "b"},
+	}
+
+	b, err := Marshal(&c)
+	check.OK(t, err)
+
+	exp := `host: "localhost"
+ports:
+	http: 8888
+	grpc: 9999
+	rest:
+		- 1
+		- 2
+startup_delay: 5.0
+verbose: false
+servers:
+	-
+		Host: "alpha"
+		port: 80
+limits:
+	cpu: 1.5
+	memory: 512.0
+extra: {}
+anything:
+	-
+		- 1
+	- {}
+pair:
+	- "a"
+	- "b"
+`
+	check.Equals(t, exp, string(b))
+
+	// and back again
+	var d testConfig
+	err = Unmarshal(b, &d)
+	check.OK(t, err)
+	c.Secret = ""
+	c.Extra = map[string]interface{}{}
+	c.Anything = []interface{}{[]interface{}{1}, map[string]interface{}{}}
+	check.Equals(t, c, d)
+}
+
+func TestMarshalOmitEmpty(t *testing.T) {
+	b, err := Marshal(testPorts{HTTP: 80})
+	check.OK(t, err)
+	check.Equals(t, "http: 80\nrest: []\n", string(b))
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	inputs := []string{input, listInput, flowInput, decodeInput}
+
+	for i := range inputs {
+		m, err := Parse(inputs[i])
+		check.OKWithMessage(t, err, "input: %d", i+1)
+
+		b, err := Marshal(m)
+		check.OKWithMessage(t, err, "input: %d", i+1)
+
+		n, err := Parse(string(b))
+		check.OKWithMessage(t, err, "input: %d", i+1)
+		check.EqualsWithMessage(t, m, n, "input: %d", i+1)
+	}
+
+	m := map[string]interface{}{
+		"negative":  -5,
+		"float":     -0.000001,
+		"large":     1e21,
+		"backslash": "C:\\path\\\\",
+		"empty":     "",
+		"quotes":    "say \"hi\"",
+		"lines":     "a\n\tb\r\x00",
+		"unicode":   "héllo ☺ 😀",
+		"nothing":   nil,
+	}
+	b, err := Marshal(m)
+	check.OK(t, err)
+	n, err := Parse(string(b))
+	check.OK(t, err)
+	check.Equals(t, m, n)
+}
+
+func TestMarshalScalar(t *testing.T) {
+	m, err := Parse("f: 1.0\nt: 10:30:00\ndt: 2024-01-15T10:30:00+02:00\nd: 2024-01-15\ns: \"a b\"\nn: null\n")
+	check.OK(t, err)
+
+	exp := map[string]string{
+		"f":  "1.0",
+		"t":  "10:30:00",
+		"dt": "2024-01-15T10:30:00+02:00",
+		"d":  "2024-01-15",
+		"s":  `"a b"`,
+		"n":  "null",
+	}
+
+	for key, v := range m {
+		s, err := MarshalScalar(v)
+		check.OK(t, err)
+		check.EqualsWithMessage(t, exp[key], s, "key %s", key)
+	}
+
+	_, err = MarshalScalar([]int{1})
+	check.NotOK(t, err)
+}
+
+func TestMarshalErrors(t *testing.T) {
+	type row struct {
+		Value interface{}
+		Error string
+	}
+
+	table := []row{
+		row{
+			Value: 5,
+			Error: "yrm: unsupported type: int at ''",
+		},
+		row{
+			Value: map[string]interface{}{"a": []interface{}{make(chan int)}},
+			Error: "yrm: unsupported type: chan int at 'a.0'",
+		},
+		row{
+			Value: map[string]interface{}{"a": "bad \xff"},
+			Error: "yrm: unsupported value: \"bad \\xff\" at 'a'",
+		},
+		row{
+			Value: map[string]interface{}{"a\xffb": 1},
+			Error: "yrm: unsupported value: \"a\\xffb\" as key at ''",
+		},
+		row{
+			Value: map[int]int{1: 1},
+			Error: "yrm: unsupported type: map[int]int at ''",
+		},
+	}
+
+	for i, r := range table {
+		_, err := Marshal(r.Value)
+		check.NotOKWithMessage(t, err, "row: %d", i+1)
+		check.EqualsWithMessage(t, r.Error, err.Error(), "row: %d", i+1)
+	}
+
+	var valueErr *UnsupportedValueError
+	_, err := Marshal(map[string]string{"a": "bad \xff"})
+	check.Assert(t, errors.As(err, &valueErr))
+}
+
+func TestQuoteMultiline(t *testing.T) {
+	values := []string{
+		"",
+		"one",
+		"one\ntwo",
+		"\n\tindented\n\n",
+		`"""` + "\n  \"\"\" quotes",
+		"back\\slash \x01",
+		"  \n\t",
+	}
+
+	for i, v := range values {
+		m, err := Parse("a:\n\tb: " + quoteMultiline(v, 1) + "\n")
+		check.OKWithMessage(t, err, "value: %d", i+1)
+		check.EqualsWithMessage(t, v, m["a"].(map[string]interface{})["b"], "value: %d", i+1)
+	}
+}
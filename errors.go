@@ -0,0 +1,16 @@
+package yrm
+
+import "github.com/doctordesh/yrm/parser"
+
+// The errors returned by Parse and ParseFile when the input is invalid. Use
+// errors.As to find out what went wrong, e.g.
+//
+//	var dup *yrm.DuplicateKeyError
+//	if errors.As(err, &dup) {
+//		fmt.Println(dup.Position, dup.Key)
+//	}
+type (
+	SyntaxError       = parser.SyntaxError
+	DuplicateKeyError = parser.DuplicateKeyError
+	IndentationError  = parser.IndentationError
+)
@@ -0,0 +1,59 @@
+package yrm
+
+import (
+	"reflect"
+	"strings"
+)
+
+// field is an exported struct field that takes part in encoding and decoding
+type field struct {
+	name      string // key in the document
+	index     int    // index of the field in the struct
+	omitEmpty bool
+}
+
+// structFields returns the fields of the struct type, named by their 'yrm'
+// tag if they have one and by the field name otherwise. Fields tagged with
+// "-" and unexported fields are left out.
+func structFields(t reflect.Type) []field {
+	var fields []field
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		tag := f.Tag.Get("yrm")
+		if tag == "-" {
+			continue
+		}
+
+		name, options := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, options = tag[:i], tag[i+1:]
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		fields = append(fields, field{
+			name:      name,
+			index:     i,
+			omitEmpty: hasOption(options, "omitempty"),
+		})
+	}
+
+	return fields
+}
+
+// hasOption reports whether the comma separated list of tag options
+// contains the option
+func hasOption(options, option string) bool {
+	for _, o := range strings.Split(options, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package yrm
+
+import (
+	"bytes"
+
+	"github.com/doctordesh/yrm/lexer"
+	"github.com/doctordesh/yrm/token"
+)
+
+// Format returns the input in its canonical form: one tab per level of
+// indentation, a single space after colons, dashes and commas, no space
+// inside brackets, at most one blank line in a row and none at the start or
+// end. Comments on their own line are indented like the line they are
+// above. Values are kept as they are written.
+func Format(src []byte) ([]byte, error) {
+	_, err := parseAST("", bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := lexer.New(string(src)).Lex()
+	if err != nil {
+		return nil, err
+	}
+
+	lines := splitLines(tokens)
+
+	var buf bytes.Buffer
+	blank := false
+	for i, l := range lines {
+		if len(l.tokens) == 0 {
+			blank = true
+			continue
+		}
+
+		if blank && buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		blank = false
+
+		depth := l.depth
+		if l.tokens[0].TokenType == token.COMMENT {
+			depth = commentDepth(lines[i+1:])
+		}
+
+		for j := 0; j < depth; j++ {
+			buf.WriteByte('\t')
+		}
+
+		for j, tok := range l.tokens {
+			if j > 0 && spaceBetween(l.tokens[j-1], tok) {
+				buf.WriteByte(' ')
+			}
+			// multi-line strings are indented like the rest
+			if tok.TokenType == token.STRING && bytes.HasPrefix(src[tok.Offset:], []byte(`"""`)) {
+				buf.WriteString(quoteMultiline(tok.Literal, depth))
+				continue
+			}
+
+			buf.Write(src[tok.Offset:tok.End])
+		}
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes(), nil
+}
+
+// line is the tokens of one line, without the indentation and the new line
+type line struct {
+	depth  int
+	tokens []token.Token
+}
+
+func splitLines(tokens []token.Token) []line {
+	var lines []line
+
+	l := line{}
+	for _, tok := range tokens {
+		switch tok.TokenType {
+		case token.TAB:
+			l.depth += 1
+		case token.NEW_LINE, token.EOF:
+			lines = append(lines, l)
+			l = line{}
+		default:
+			l.tokens = append(l.tokens, tok)
+		}
+	}
+
+	return lines
+}
+
+// commentDepth returns the depth of the first line that isn't blank or a
+// comment, or 0 if there is none
+func commentDepth(lines []line) int {
+	for _, l := range lines {
+		if len(l.tokens) > 0 && l.tokens[0].TokenType != token.COMMENT {
+			return l.depth
+		}
+	}
+	return 0
+}
+
+// spaceBetween reports whether a space goes between the two tokens of a line
+func spaceBetween(prev, tok token.Token) bool {
+	switch tok.TokenType {
+	case token.COLON_SIGN, token.COMMA, token.RIGHT_BRACKET, token.RIGHT_BRACE:
+		return false
+	}
+
+	switch prev.TokenType {
+	case token.LEFT_BRACKET, token.LEFT_BRACE:
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,42 @@
+package yrm
+
+import (
+	"testing"
+
+	check "gitlab.com/MaxIV/lib-maxiv-go-check"
+)
+
+func TestFormat(t *testing.T) {
+	type row struct {
+		input string
+		exp   string
+	}
+
+	table := []row{
+		{"a:1", "a: 1\n"},
+		{"a:   1   \n", "a: 1\n"},
+		{"\n\n// top\na: 1\n\n\n\nb: 2\n\n\n", "// top\na: 1\n\nb: 2\n"},
+		{"a:  \n\tb:\"x\"\n", "a:\n\tb: \"x\"\n"},
+		{"a:\n    b: 1\n    c:\n        - 2\n", "a:\n\tb: 1\n\tc:\n\t\t- 2\n"},
+		{"a:\n\t-   1\n\t-\n\t\tb: 2\n", "a:\n\t- 1\n\t-\n\t\tb: 2\n"},
+		{"a: [ 1 ,2,  [3] ]\nb: {x:1,y: { }}\n", "a: [1, 2, [3]]\nb: {x: 1, y: {}}\n"},
+		{"a:\n// the b\n\tb: 1\n\t\t// after\nc: 2\n// end\n", "a:\n\t// the b\n\tb: 1\n// after\nc: 2\n// end\n"},
+		{"", ""},
+		{"a:\n    b:   \"\"\"\n        one\n\n          two\n    \"\"\"\n", "a:\n\tb: \"\"\"\n\t\tone\n\n\t\t  two\n\t\t\"\"\"\n"},
+		{"a:  `raw\n  text`\n", "a: `raw\n  text`\n"},
+	}
+
+	for i, r := range table {
+		b, err := Format([]byte(r.input))
+		check.OK(t, err)
+		check.EqualsWithMessage(t, r.exp, string(b), "row %d", i)
+
+		// formatting is stable
+		again, err := Format(b)
+		check.OK(t, err)
+		check.EqualsWithMessage(t, string(b), string(again), "row %d", i)
+	}
+
+	_, err := Format([]byte("a: 1\na: 2\n"))
+	check.NotOK(t, err)
+}
@@ -1,8 +1,9 @@
 module github.com/doctordesh/yrm
 
-go 1.16
+go 1.18
 
 require (
 	github.com/doctordesh/check v0.0.0-20200914125451-e201dda22edb // indirect
 	gitlab.com/MaxIV/lib-maxiv-go-check v1.0.1
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,225 @@
+package yrm
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ToINI converts the YRM input to INI. Keys with a map as value become
+// sections, and the keys before the first section are those at the top level.
+// Keys are sorted, and null is written as an empty value. INI has no lists
+// and no sections within sections, so lists and maps nested in a section are
+// returned as a *ConvertError. So are keys and values INI has no way to
+// write, such as keys with '=' or ']' in them, and values with ';' or '#' in
+// them or spaces around them.
+func ToINI(src []byte) ([]byte, error) {
+	m, err := parse("", bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	keys := sortedKeys(m)
+
+	for _, key := range keys {
+		if _, ok := m[key].(map[string]interface{}); ok {
+			continue
+		}
+
+		err := writeINIValue(&buf, []string{key}, m[key])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, key := range keys {
+		section, ok := m[key].(map[string]interface{})
+		if ok == false {
+			continue
+		}
+
+		err := checkINIKey([]string{key})
+		if err != nil {
+			return nil, err
+		}
+
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "[%s]\n", key)
+
+		for _, k := range sortedKeys(section) {
+			err := writeINIValue(&buf, []string{key, k}, section[k])
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeINIValue writes a 'key = value' line
+func writeINIValue(buf *bytes.Buffer, path []string, v interface{}) error {
+	err := checkINIKey(path)
+	if err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return &ConvertError{Path: path, Msg: "INI cannot nest a map more than one level deep"}
+	case []interface{}:
+		return &ConvertError{Path: path, Msg: "INI has no lists"}
+	case string:
+		if strings.ContainsAny(v, "\r\n") {
+			return &ConvertError{Path: path, Msg: "INI values cannot span lines"}
+		}
+		if strings.ContainsAny(v, ";#") {
+			return &ConvertError{Path: path, Msg: fmt.Sprintf("INI values cannot contain %q, it starts a comment", v[strings.IndexAny(v, ";#")])}
+		}
+		if strings.TrimSpace(v) != v {
+			return &ConvertError{Path: path, Msg: "INI values cannot start or end with spaces"}
+		}
+		fmt.Fprintf(buf, "%s = %s\n", path[len(path)-1], v)
+		return nil
+	case nil:
+		fmt.Fprintf(buf, "%s =\n", path[len(path)-1])
+		return nil
+	}
+
+	s, err := scalar(path, reflect.ValueOf(v))
+	if err != nil {
+		return &ConvertError{Path: path, Msg: fmt.Sprintf("%v has no INI equivalent", v)}
+	}
+
+	fmt.Fprintf(buf, "%s = %s\n", path[len(path)-1], s)
+	return nil
+}
+
+// checkINIKey returns a *ConvertError if the last key of the path cannot be
+// written as an INI key or section name, as INI has no way to escape them
+func checkINIKey(path []string) error {
+	key := path[len(path)-1]
+
+	if key == "" {
+		return &ConvertError{Path: path, Msg: "INI keys cannot be empty"}
+	}
+	if strings.ContainsAny(key, "=:;#[]\r\n") {
+		return &ConvertError{Path: path, Msg: fmt.Sprintf("INI keys cannot contain %q", key[strings.IndexAny(key, "=:;#[]\r\n")])}
+	}
+	if strings.TrimSpace(key) != key {
+		return &ConvertError{Path: path, Msg: "INI keys cannot start or end with spaces"}
+	}
+	return nil
+}
+
+// ToProperties converts the YRM input to a Java .properties file, with one
+// 'dotted.key = value' line per value and list items by their index, e.g.
+// 'servers.0.host'. Keys are sorted, and null is written as an empty value.
+// Empty maps and lists have no lines to be written on, and are returned as a
+// *ConvertError.
+func ToProperties(src []byte) ([]byte, error) {
+	m, err := parse("", bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = writeProperties(&buf, nil, m)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeProperties(buf *bytes.Buffer, path []string, v interface{}) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 && len(path) > 0 {
+			return &ConvertError{Path: path, Msg: "an empty map has no properties equivalent"}
+		}
+
+		for _, key := range sortedKeys(v) {
+			err := writeProperties(buf, appendPath(path, key), v[key])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		if len(v) == 0 {
+			return &ConvertError{Path: path, Msg: "an empty list has no properties equivalent"}
+		}
+
+		for i, item := range v {
+			err := writeProperties(buf, appendPath(path, strconv.Itoa(i)), item)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	s, ok := v.(string)
+	if v == nil {
+		s = ""
+	} else if ok == false {
+		var err error
+		s, err = scalar(path, reflect.ValueOf(v))
+		if err != nil {
+			return &ConvertError{Path: path, Msg: fmt.Sprintf("%v has no properties equivalent", v)}
+		}
+	}
+
+	fmt.Fprintf(buf, "%s = %s\n", propertiesEscape(strings.Join(path, "."), true), propertiesEscape(s, false))
+	return nil
+}
+
+// propertiesEscape escapes the key or value as a .properties file needs,
+// with characters outside of printable ASCII written as '\uXXXX', since the
+// file is read as ISO 8859-1
+func propertiesEscape(s string, key bool) string {
+	var b strings.Builder
+
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == ' ' && (key || i == 0):
+			b.WriteString(`\ `)
+		case key && strings.ContainsRune("=:#!", r):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			writeUnicodeEscape(&b, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// writeUnicodeEscape writes the rune as '\uXXXX', as a surrogate pair if it
+// doesn't fit in one
+func writeUnicodeEscape(b *strings.Builder, r rune) {
+	if r > 0xffff {
+		r -= 0x10000
+		fmt.Fprintf(b, `\u%04X\u%04X`, 0xd800+(r>>10), 0xdc00+(r&0x3ff))
+		return
+	}
+	fmt.Fprintf(b, `\u%04X`, r)
+}
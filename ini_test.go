@@ -0,0 +1,87 @@
+package yrm
+
+import (
+	"testing"
+
+	check "gitlab.com/MaxIV/lib-maxiv-go-check"
+)
+
+func TestToINI(t *testing.T) {
+	input := `name: "server"
+ports:
+	https: 443
+	http: 80
+debug:
+	verbose: true
+ratio: 0.5
+`
+
+	b, err := ToINI([]byte(input))
+	check.OK(t, err)
+
+	exp := `name = server
+ratio = 0.5
+
+[debug]
+verbose = true
+
+[ports]
+http = 80
+https = 443
+`
+	check.Equals(t, exp, string(b))
+
+	type row struct {
+		input string
+		exp   string
+	}
+
+	table := []row{
+		{"a:\n\tb:\n\t\tc: 1\n", "yrm: cannot convert 'a.b': INI cannot nest a map more than one level deep"},
+		{"a: [1, 2]\n", "yrm: cannot convert 'a': INI has no lists"},
+		{"\"a=b\": 1\n", "yrm: cannot convert 'a=b': INI keys cannot contain '='"},
+		{"\"a\\nb\": 1\n", "yrm: cannot convert 'a\nb': INI keys cannot contain '\\n'"},
+		{"\" a\": 1\n", "yrm: cannot convert ' a': INI keys cannot start or end with spaces"},
+		{"\"s]e[c\":\n\ta: 1\n", "yrm: cannot convert 's]e[c': INI keys cannot contain ']'"},
+		{"s:\n\t\"k;\": 1\n", "yrm: cannot convert 's.k;': INI keys cannot contain ';'"},
+		{"k: \"v ; x\"\n", "yrm: cannot convert 'k': INI values cannot contain ';', it starts a comment"},
+		{"s:\n\tk: \"#v\"\n", "yrm: cannot convert 's.k': INI values cannot contain '#', it starts a comment"},
+		{"lead: \"  sp\"\n", "yrm: cannot convert 'lead': INI values cannot start or end with spaces"},
+		{"trail: \"sp\\t\"\n", "yrm: cannot convert 'trail': INI values cannot start or end with spaces"},
+	}
+
+	for i, r := range table {
+		_, err := ToINI([]byte(r.input))
+		check.NotOK(t, err)
+		check.EqualsWithMessage(t, r.exp, err.Error(), "row %d", i)
+	}
+}
+
+func TestToProperties(t *testing.T) {
+	input := `name: " server: é"
+ports:
+	http: 80
+servers:
+	- {host: "a", tags: [1]}
+	- "b"
+`
+
+	b, err := ToProperties([]byte(input))
+	check.OK(t, err)
+
+	exp := `name = \ server: \u00E9
+ports.http = 80
+servers.0.host = a
+servers.0.tags.0 = 1
+servers.1 = b
+`
+	check.Equals(t, exp, string(b))
+
+	_, err = ToProperties([]byte("a:\n\tb: {}\n"))
+	check.NotOK(t, err)
+	check.Equals(t, "yrm: cannot convert 'a.b': an empty map has no properties equivalent", err.Error())
+
+	check.Equals(t, `a\ b\=c\:d`, propertiesEscape("a b=c:d", true))
+	check.Equals(t, `\u263A\uD83D\uDE00`, propertiesEscape("☺😀", false))
+	check.Equals(t, `caf\u00E9\u007F`, propertiesEscape("café\x7f", false))
+}
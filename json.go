@@ -0,0 +1,277 @@
+package yrm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/doctordesh/yrm/ast"
+)
+
+// ToJSON converts the YRM input to indented JSON. Keys are written in the
+// order of the input, ints stay ints and floats are written with a decimal
+// point, e.g. '5.0'.
+func ToJSON(src []byte) ([]byte, error) {
+	file, err := parseAST("", bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	return indentJSON(file.Map)
+}
+
+// indentJSON returns the node as indented JSON, ending with a new line
+func indentJSON(node ast.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	err := writeJSON(&buf, nil, node)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	err = json.Indent(&out, buf.Bytes(), "", "    ")
+	if err != nil {
+		return nil, err
+	}
+
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// writeJSON writes the node as compact JSON
+func writeJSON(buf *bytes.Buffer, path []string, node ast.Node) error {
+	switch n := node.(type) {
+	case *ast.MapNode:
+		buf.WriteByte('{')
+		for i, e := range n.Entries {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			err := writeJSONString(buf, e.Key.Literal)
+			if err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+
+			err = writeJSON(buf, appendPath(path, e.Key.Literal), e.Value)
+			if err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	case *ast.ListNode:
+		buf.WriteByte('[')
+		for i, item := range n.Items {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			err := writeJSON(buf, appendPath(path, strconv.Itoa(i)), item.Value)
+			if err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	case *ast.ScalarNode:
+		switch v := n.Value.(type) {
+		case string:
+			return writeJSONString(buf, v)
+		case time.Duration:
+			return writeJSONString(buf, v.String())
+		case time.Time:
+			return writeJSONString(buf, formatTime(v))
+		case Date:
+			return writeJSONString(buf, v.String())
+		case ByteSize:
+			buf.WriteString(strconv.FormatInt(int64(v), 10))
+			return nil
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return &ConvertError{Path: path, Msg: fmt.Sprintf("%v has no JSON equivalent", v)}
+			}
+		}
+
+		s, err := scalar(path, reflect.ValueOf(n.Value))
+		if err != nil {
+			return &ConvertError{Path: path, Msg: fmt.Sprintf("%v has no JSON equivalent", n.Value)}
+		}
+
+		buf.WriteString(s)
+		return nil
+	}
+
+	return fmt.Errorf("yrm: unknown node %T", node)
+}
+
+// writeJSONString writes the string as JSON, without escaping HTML
+func writeJSONString(buf *bytes.Buffer, s string) error {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+
+	err := enc.Encode(s)
+	if err != nil {
+		return err
+	}
+
+	// Encode ends the value with a new line
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
+
+// FromJSON converts the JSON input to YRM. The top level value must be an
+// object. Keys are written in the order of the input, and numbers with a
+// decimal point or an exponent become floats while the rest become ints.
+//
+// JSON that YRM cannot express, such as duplicate keys or numbers out of
+// range, is returned as a *ConvertError.
+func FromJSON(src []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(src))
+	dec.UseNumber()
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("yrm: invalid JSON: %w", err)
+	}
+
+	if tok != json.Delim('{') {
+		return nil, &ConvertError{Msg: fmt.Sprintf("the top level must be an object, got %s", describeJSON(tok))}
+	}
+
+	m, err := readJSONObject(dec, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = dec.Token()
+	if err != io.EOF {
+		return nil, errors.New("yrm: invalid JSON: more than one value at the top level")
+	}
+
+	e := &encoder{}
+	err = e.encodeEntries(nil, m, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.buf.Bytes(), nil
+}
+
+// readJSONValue reads the next JSON value, keeping the order of objects
+func readJSONValue(dec *json.Decoder, path []string) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("yrm: invalid JSON: %w", err)
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '{' {
+			return readJSONObject(dec, path)
+		}
+		return readJSONArray(dec, path)
+	case json.Number:
+		if strings.ContainsAny(t.String(), ".eE") {
+			f, err := t.Float64()
+			if err != nil {
+				return nil, &ConvertError{Path: path, Msg: fmt.Sprintf("number %s is out of range", t)}
+			}
+			return f, nil
+		}
+
+		i, err := strconv.Atoi(t.String())
+		if err == nil {
+			return i, nil
+		}
+		u, err := strconv.ParseUint(t.String(), 10, 64)
+		if err != nil {
+			return nil, &ConvertError{Path: path, Msg: fmt.Sprintf("number %s is out of range", t)}
+		}
+		return u, nil
+	}
+
+	// string, bool or nil for null
+	return tok, nil
+}
+
+// readJSONObject reads the entries of an object, after its '{'
+func readJSONObject(dec *json.Decoder, path []string) (orderedMap, error) {
+	m := orderedMap{}
+	seen := map[string]bool{}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("yrm: invalid JSON: %w", err)
+		}
+
+		key := tok.(string)
+		if seen[key] {
+			return nil, &ConvertError{Path: path, Msg: fmt.Sprintf("duplicate key '%s'", key)}
+		}
+		seen[key] = true
+
+		value, err := readJSONValue(dec, appendPath(path, key))
+		if err != nil {
+			return nil, err
+		}
+
+		m = append(m, entry{key: key, value: reflect.ValueOf(value)})
+	}
+
+	// the closing '}'
+	_, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("yrm: invalid JSON: %w", err)
+	}
+
+	return m, nil
+}
+
+// readJSONArray reads the values of an array, after its '['
+func readJSONArray(dec *json.Decoder, path []string) ([]interface{}, error) {
+	list := []interface{}{}
+
+	for dec.More() {
+		value, err := readJSONValue(dec, appendPath(path, strconv.Itoa(len(list))))
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, value)
+	}
+
+	// the closing ']'
+	_, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("yrm: invalid JSON: %w", err)
+	}
+
+	return list, nil
+}
+
+// describeJSON describes a JSON token for an error
+func describeJSON(tok json.Token) string {
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '[' {
+			return "an array"
+		}
+	case json.Number:
+		return "a number"
+	case string:
+		return "a string"
+	case bool:
+		return "a bool"
+	}
+	return "null"
+}
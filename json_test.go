@@ -0,0 +1,100 @@
+package yrm
+
+import (
+	"errors"
+	"testing"
+
+	check "gitlab.com/MaxIV/lib-maxiv-go-check"
+)
+
+func TestToJSON(t *testing.T) {
+	input := `// comments are dropped
+zeta: 1
+alpha: 5.0
+list:
+	- "a<b"
+	- {x: true, y: []}
+`
+
+	b, err := ToJSON([]byte(input))
+	check.OK(t, err)
+
+	exp := `{
+    "zeta": 1,
+    "alpha": 5.0,
+    "list": [
+        "a<b",
+        {
+            "x": true,
+            "y": []
+        }
+    ]
+}
+`
+	check.Equals(t, exp, string(b))
+
+	b, err = ToJSON([]byte("timeout: 1m30s\nbuffer: 1KiB\n"))
+	check.OK(t, err)
+	check.Equals(t, "{\n    \"timeout\": \"1m30s\",\n    \"buffer\": 1024\n}\n", string(b))
+
+	_, err = ToJSON([]byte("a: 1\na: 2\n"))
+	check.NotOK(t, err)
+}
+
+func TestFromJSON(t *testing.T) {
+	input := `{"zeta": 1, "alpha": 5.0, "big": 1e3, "nested": {"b": "x", "a": [1, {"c": false}]}, "empty": {}, "none": null, "my key": "x-1"}`
+
+	b, err := FromJSON([]byte(input))
+	check.OK(t, err)
+
+	exp := `zeta: 1
+alpha: 5.0
+big: 1000.0
+nested:
+	b: "x"
+	a:
+		- 1
+		-
+			c: false
+empty: {}
+none: null
+"my key": "x-1"
+`
+	check.Equals(t, exp, string(b))
+
+	// and back again
+	b, err = ToJSON(b)
+	check.OK(t, err)
+
+	b, err = FromJSON(b)
+	check.OK(t, err)
+	check.Equals(t, exp, string(b))
+}
+
+func TestFromJSONErrors(t *testing.T) {
+	type row struct {
+		input string
+		exp   string
+	}
+
+	table := []row{
+		{`[1, 2]`, "yrm: cannot convert: the top level must be an object, got an array"},
+		{`{"a": 1, "a": 2}`, "yrm: cannot convert: duplicate key 'a'"},
+		{`{"a": [1, 99999999999999999999]}`, "yrm: cannot convert 'a.1': number 99999999999999999999 is out of range"},
+		{`{"a": 1} {}`, "yrm: invalid JSON: more than one value at the top level"},
+	}
+
+	for i, r := range table {
+		_, err := FromJSON([]byte(r.input))
+		check.NotOK(t, err)
+		check.EqualsWithMessage(t, r.exp, err.Error(), "row %d", i)
+	}
+
+	_, err := FromJSON([]byte(`{"a": }`))
+	check.NotOK(t, err)
+
+	var convertErr *ConvertError
+	_, err = FromJSON([]byte(`{"a": {"b": 1, "b": 2}}`))
+	check.Assert(t, errors.As(err, &convertErr))
+	check.Equals(t, []string{"a"}, convertErr.Path)
+}
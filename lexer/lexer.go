@@ -1,14 +1,21 @@
 package lexer
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"log"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/doctordesh/yrm/ast"
 	"github.com/doctordesh/yrm/token"
 )
 
-const eof = byte(0)
+const (
+	eof     = rune(-1)
+	invalid = rune(-2) // a byte that is not part of valid UTF-8
+)
 
 // ==================================================
 //
@@ -17,35 +24,131 @@ const eof = byte(0)
 // ==================================================
 
 type lexer struct {
-	Verbose bool
-
-	input      string // string being scanned
-	start      int    // start position of this token
-	position   int    // current position in the input
+	Verbose  bool
+	Filename string // used in the position of each token
+
+	reader     *bufio.Reader // input being scanned
+	buffer     []byte        // input read so far, from the start of this token
+	offset     int           // position in the input of buffer[0]
+	readErr    error         // error from the reader, other than io.EOF
+	start      int           // start position of this token
+	position   int           // current position in the input
+	width      int           // width of the last rune read by next
+	line       int           // number of new lines before start
+	lineStart  int           // position of the first byte on the line of start
 	tokens     []token.Token
 	tokenIndex int // reading pointer for 'NextToken'
+	last       token.Token
+
+	// open flow collections, innermost last. Holds '[' and '{'
+	flow []byte
+
+	// IndentWidth is the number of spaces per level of indentation. If it's
+	// 0 it's set by the first line that is indented with spaces.
+	IndentWidth int
+	indent      byte // '\t' or ' ' once the first line has been indented
+	indentLine  int  // the line that set indent
+	levels      int  // levels of indentation of the current line
 
 	startState stateFn
+	state      stateFn
+	started    bool
 }
 
+// New returns a lexer for the input string
 func New(input string) *lexer {
+	return NewReader(strings.NewReader(input))
+}
+
+// NewReader returns a lexer that reads its input from r as it goes, so the
+// input never has to be held in memory as a whole
+func NewReader(r io.Reader) *lexer {
 	l := &lexer{
-		input:      input,
+		reader:     bufio.NewReader(r),
 		startState: lexNewLine,
 	}
 
 	return l
 }
 
-// Lex ...
+// Error is returned by Lex when the input could not be tokenized
+type Error struct {
+	Position token.Position
+	Msg      string
+}
+
+func (self *Error) Error() string {
+	return fmt.Sprintf("%s: %s", self.Position, self.Msg)
+}
+
+// Lex tokenizes the whole input. It stops at the first illegal token and
+// returns it as an *Error, together with the tokens up to and including the
+// illegal one.
 func (self *lexer) Lex() ([]token.Token, error) {
-	for state := self.startState; state != nil; {
-		state = state(self)
+	for self.step() {
+	}
+
+	if self.readErr != nil {
+		return self.tokens, self.readErr
+	}
+
+	if n := len(self.tokens); n > 0 && self.tokens[n-1].TokenType == token.ILLEGAL {
+		tok := self.tokens[n-1]
+		return self.tokens, &Error{Position: tok.Position, Msg: tok.Literal}
 	}
 
 	return self.tokens, nil
 }
 
+// Next returns the next token, only reading as much of the input as it takes
+// to produce it. An illegal token is returned together with an *Error. Once
+// the input is exhausted, the last token (EOF or ILLEGAL) is returned again.
+//
+// Next and Lex are two different ways to use the lexer and should not be
+// mixed.
+func (self *lexer) Next() (token.Token, error) {
+	for self.tokenIndex >= len(self.tokens) && self.step() {
+	}
+
+	tok := self.last
+	if self.tokenIndex < len(self.tokens) {
+		tok = self.tokens[self.tokenIndex]
+		self.tokenIndex += 1
+		self.last = tok
+	}
+
+	// Tokens that have been handed out are not needed anymore
+	if self.tokenIndex >= len(self.tokens) {
+		self.tokens = self.tokens[:0]
+		self.tokenIndex = 0
+	}
+
+	if self.readErr != nil {
+		return tok, self.readErr
+	}
+
+	if tok.TokenType == token.ILLEGAL {
+		return tok, &Error{Position: tok.Position, Msg: tok.Literal}
+	}
+
+	return tok, nil
+}
+
+// step runs the current state function, and reports whether there was one
+func (self *lexer) step() bool {
+	if self.started == false {
+		self.state = self.startState
+		self.started = true
+	}
+
+	if self.state == nil {
+		return false
+	}
+
+	self.state = self.state(self)
+	return true
+}
+
 // nextToken used internally to get one token at a time. Good for tests
 func (self *lexer) nextToken() (token.Token, error) {
 	var t token.Token
@@ -59,44 +162,126 @@ func (self *lexer) nextToken() (token.Token, error) {
 	return t, nil
 }
 
-// next
-func (self *lexer) next() byte {
-	if self.position >= len(self.input) {
+// fill makes sure that the byte at position p has been read into the buffer,
+// and reports whether there is such a byte
+func (self *lexer) fill(p int) bool {
+	for p-self.offset >= len(self.buffer) {
+		if self.readErr != nil {
+			return false
+		}
+
+		b, err := self.reader.ReadByte()
+		if err == io.EOF {
+			return false
+		}
+		if err != nil {
+			self.readErr = err
+			return false
+		}
+
+		self.buffer = append(self.buffer, b)
+	}
+
+	return true
+}
+
+// next moves past the current rune and returns the one after it
+func (self *lexer) next() rune {
+	if self.fill(self.position) == false {
+		self.width = 0
 		return eof
 	}
 
-	self.position += 1
+	_, self.width = self.decode()
+	self.position += self.width
 
 	return self.current()
 }
 
+// decode returns the rune at the current position and its width in bytes.
+// A byte that is not valid UTF-8 is returned as 'invalid' with width 1.
+func (self *lexer) decode() (rune, int) {
+	self.fill(self.position + utf8.UTFMax - 1)
+
+	r, width := utf8.DecodeRune(self.buffer[self.position-self.offset:])
+	if r == utf8.RuneError && width == 1 {
+		return invalid, 1
+	}
+	return r, width
+}
+
 // peek returns but does not consume the next rune in the input.
-func (self *lexer) peek() byte {
+func (self *lexer) peek() rune {
 	r := self.next()
 	self.backup()
 	return r
 }
 
-// backup ...
+// backup steps back over the rune read by the last call to next. It can
+// only be called once per call to next.
 func (self *lexer) backup() {
-	self.position -= 1
+	self.position -= self.width
 }
 
 // ignore ...
 func (self *lexer) ignore() {
-	self.start = self.position
+	self.moveStart(self.position)
+}
+
+// moveStart moves the start of the next token forward to 'to', keeping
+// track of which line it ends up on. The input before the new start is
+// dropped from the buffer.
+func (self *lexer) moveStart(to int) {
+	self.fill(to - 1)
+	for i := self.start; i < to && i-self.offset < len(self.buffer); i++ {
+		if self.buffer[i-self.offset] == '\n' {
+			self.line += 1
+			self.lineStart = i + 1
+			self.levels = 0
+		}
+	}
+	self.start = to
+
+	if drop := to - self.offset; drop > 0 && drop <= len(self.buffer) {
+		self.buffer = self.buffer[drop:]
+		self.offset = to
+	}
+}
+
+// startPosition returns the position of the start of the current token
+func (self *lexer) startPosition() token.Position {
+	return token.Position{
+		Filename: self.Filename,
+		Offset:   self.start,
+		Line:     self.line + 1,
+		Column:   self.start - self.lineStart + 1,
+	}
+}
+
+// text returns the input from the start of this token up to the current
+// position
+func (self *lexer) text() string {
+	self.fill(self.position - 1)
+	return string(self.buffer[self.start-self.offset : self.position-self.offset])
 }
 
 // emit ...
 func (self *lexer) emit(tokenType token.TokenType) {
-	start := self.start
+	self.emitLiteral(tokenType, self.text())
+}
+
+// emitLiteral emits a token with a literal that differs from its text in the
+// input, e.g. a string without its quotes
+func (self *lexer) emitLiteral(tokenType token.TokenType, literal string) {
 	end := self.position
 	tok := token.Token{
 		TokenType: tokenType,
-		Literal:   self.input[start:end],
+		Literal:   literal,
+		Position:  self.startPosition(),
+		End:       end,
 	}
 
-	self.start = end
+	self.moveStart(end)
 	self.position = end
 	self.tokens = append(self.tokens, tok)
 }
@@ -107,23 +292,57 @@ func (self *lexer) illegal(format string, args ...interface{}) stateFn {
 	tok := token.Token{
 		TokenType: token.ILLEGAL,
 		Literal:   fmt.Sprintf(format, args...),
+		Position:  self.startPosition(),
 	}
 
 	self.tokens = append(self.tokens, tok)
 	return nil
 }
 
-// current ...
-func (self *lexer) current() byte {
-	if self.position >= len(self.input) {
+// lookingAt reports whether the input at the current position starts with s
+func (self *lexer) lookingAt(s string) bool {
+	if self.fill(self.position+len(s)-1) == false {
+		return false
+	}
+
+	i := self.position - self.offset
+	return string(self.buffer[i:i+len(s)]) == s
+}
+
+// lookingAtPattern reports whether the input at the current position starts
+// with the pattern, where 'd' matches any digit and every other byte matches
+// itself
+func (self *lexer) lookingAtPattern(pattern string) bool {
+	if self.fill(self.position+len(pattern)-1) == false {
+		return false
+	}
+
+	i := self.position - self.offset
+	for j := 0; j < len(pattern); j++ {
+		b := self.buffer[i+j]
+		if pattern[j] == 'd' && (b < '0' || b > '9') {
+			return false
+		}
+		if pattern[j] != 'd' && b != pattern[j] {
+			return false
+		}
+	}
+	return true
+}
+
+// current returns the rune at the current position
+func (self *lexer) current() rune {
+	if self.fill(self.position) == false {
 		return eof
 	}
-	return self.input[self.position]
+
+	r, _ := self.decode()
+	return r
 }
 
-// accept consumes the next byte if it's from the valid set.
+// accept consumes the next rune if it's from the valid set.
 func (self *lexer) accept(valid string) bool {
-	if strings.Contains(valid, byteToString(self.next())) {
+	if strings.ContainsRune(valid, self.next()) {
 		return true
 	}
 	self.backup()
@@ -132,7 +351,7 @@ func (self *lexer) accept(valid string) bool {
 
 // acceptRun consumes a run of runes from the valid set.
 func (self *lexer) acceptRun(valid string) {
-	for strings.Contains(valid, byteToString(self.next())) {
+	for strings.ContainsRune(valid, self.next()) {
 	}
 
 	self.backup()
@@ -141,7 +360,11 @@ func (self *lexer) acceptRun(valid string) {
 // errorf returns an error token and terminates the scan by passing
 // back a nil pointer that will be the next state, terminating l.nextItem.
 func (self *lexer) errorf(format string, args ...interface{}) stateFn {
-	tok := token.Token{token.ILLEGAL, fmt.Sprintf(format, args...)}
+	tok := token.Token{
+		TokenType: token.ILLEGAL,
+		Literal:   fmt.Sprintf(format, args...),
+		Position:  self.startPosition(),
+	}
 	self.tokens = append(self.tokens, tok)
 	return nil
 }
@@ -155,15 +378,15 @@ func (self *lexer) printState(key string) {
 	} else if b == '\t' {
 		current = "\\t"
 	} else {
-		current = byteToString(b)
+		current = string(b)
 	}
 	log.Printf(
-		"%s; start: %d, position: %d, current: %s, length: %d\n",
+		"%s; start: %d, position: %d, current: %s, buffered: %d\n",
 		key,
 		self.start,
 		self.position,
 		current,
-		len(self.input),
+		len(self.buffer),
 	)
 }
 
@@ -184,32 +407,107 @@ func lexNewLine(l *lexer) stateFn {
 		l.next()
 		l.emit(token.NEW_LINE)
 		return lexNewLine
-	case b == '\t':
-		l.next()
-		l.emit(token.TAB)
-		return lexNewLine
+	case b == '\t' || b == ' ':
+		return lexIndent(l)
 	case b == '/':
 		return lexComment
+	case b == '-':
+		return lexDash
 	case isLetter(b):
 		return lexIdentifier
-	case b == ' ':
-		l.next()
-		return lexNewLine
+	case b == '"':
+		return lexQuotedKey
 	case b == eof:
 		l.emit(token.EOF)
 		return nil
 	}
 
+	return l.errorf("unexpected character '%s' at start of line", string(l.current()))
+}
+
+// lexIndent reads the whitespace at the start of a line and emits one TAB
+// token per level of indentation. A level is a tab, or a number of spaces set
+// by IndentWidth or by the first line indented with spaces. All lines must be
+// indented the same way, and a line can't mix tabs and spaces.
+//
+// Blank lines and comments don't count: the indentation of blank lines is
+// dropped, and that of comments unless it's all tabs.
+func lexIndent(l *lexer) stateFn {
+	if l.Verbose {
+		log.Println("===== lexIndent")
+	}
+
+	for b := l.current(); b == '\t' || b == ' '; b = l.next() {
+	}
+
+	end := l.position
+	text := l.text()
+
+	if b := l.current(); b == '\n' || b == '/' || b == eof {
+		if b != '/' || strings.Trim(text, "\t") != "" {
+			l.ignore()
+			return lexNewLine
+		}
+		return emitIndent(l, 1, len(text), end)
+	}
+
+	if i := strings.IndexByte(text, text[0]^('\t'^' ')); i >= 0 {
+		l.moveStart(l.start + i)
+		return l.errorf("indentation mixes tabs and spaces")
+	}
+
+	if l.indent == 0 {
+		l.indent = text[0]
+		l.indentLine = l.line + 1
+		if l.indent == ' ' && l.IndentWidth == 0 {
+			l.IndentWidth = len(text)
+		}
+	}
+
+	if text[0] != l.indent {
+		return l.errorf("indented with %s, but line %d is indented with %s", indentName(text[0]), l.indentLine, indentName(l.indent))
+	}
+
+	if l.indent == '\t' {
+		return emitIndent(l, 1, len(text), end)
+	}
+
+	if len(text)%l.IndentWidth != 0 {
+		return l.errorf("indentation of %d spaces is not a multiple of %d", len(text), l.IndentWidth)
+	}
+
+	return emitIndent(l, l.IndentWidth, len(text)/l.IndentWidth, end)
+}
+
+// emitIndent emits n TAB tokens of width bytes each, and moves on to the
+// rest of the line at end
+func emitIndent(l *lexer, width, n int, end int) stateFn {
+	for i := 0; i < n; i++ {
+		l.position = l.start + width
+		l.emit(token.TAB)
+	}
+
+	l.levels = n
+	l.position = end
 	return lexNewLine
 }
 
+func indentName(b byte) string {
+	if b == '\t' {
+		return "tabs"
+	}
+	return "spaces"
+}
+
+// lexIdentifier lexes a key, which starts with a letter or underscore and
+// may go on with digits and dashes, e.g. 'x-request-id' or 'http2'
 func lexIdentifier(l *lexer) stateFn {
 	if l.Verbose {
 		log.Println("===== lexIdentifier")
 	}
 	for {
 		b := l.peek()
-		if isLetter(b) {
+		if isLetter(b) || isDigit(b) || b == '-' {
 			l.next()
 			continue
 		}
@@ -221,12 +519,48 @@ func lexIdentifier(l *lexer) stateFn {
 	return lexColon
 }
 
+// lexQuotedKey lexes a key in double quotes, which can hold any text. It's
+// emitted as an identifier without its quotes.
+func lexQuotedKey(l *lexer) stateFn {
+	if l.Verbose {
+		log.Println("===== lexQuotedKey")
+	}
+
+	if l.lookingAt(`"""`) {
+		return l.errorf("a key can't be a multi-line string")
+	}
+
+	return lexQuoted(l, token.IDENTIFIER, lexColon)
+}
+
+func lexDash(l *lexer) stateFn {
+	if l.Verbose {
+		log.Println("===== lexDash")
+	}
+
+	if l.current() != '-' {
+		return l.illegal("expected '-'")
+	}
+	l.next()
+
+	// A list item must be separated from its value, otherwise '-5' would be
+	// read as the item '5'
+	switch l.current() {
+	case ' ', '\t', '\n', eof:
+	default:
+		return l.errorf("expected whitespace after '-'")
+	}
+
+	l.emit(token.DASH)
+	return lexValue
+}
+
 func lexColon(l *lexer) stateFn {
 	if l.Verbose {
 		log.Println("===== lexColon")
 	}
 
-	if l.current() != byte(':') {
+	if l.current() != ':' {
 		return l.illegal("expected ':'")
 	}
 	l.next()
@@ -238,7 +572,7 @@ func lexComment(l *lexer) stateFn {
 	if l.Verbose {
 		log.Println("===== lexComment")
 	}
-	var b byte
+	var b rune
 	b = l.current()
 	if b != '/' {
 		panic("we should not be in this function if there was not a forward slash '/'")
@@ -251,6 +585,9 @@ func lexComment(l *lexer) stateFn {
 
 	for {
 		switch b := l.next(); {
+		case b == invalid:
+			l.moveStart(l.position)
+			return l.errorf("invalid UTF-8 encoding in comment")
 		case b == '\n':
 			l.emit(token.COMMENT)
 			l.next()
@@ -282,30 +619,64 @@ func lexValue(l *lexer) stateFn {
 	// 3. quote sign " -> lexString
 	// 4. A letter -> lexBoolean (true, false)
 	// 5. New line -> lexNewLine (dict or list)
+	// 6. Brackets, braces and commas -> flow collections ([1, 2], {a: 1})
 
 	// Consume whitespace
 	if l.current() == ' ' || l.current() == '\t' {
 		l.acceptRun(" \t")
+		l.next()
 		l.ignore()
 	}
 
+	if len(l.flow) > 0 {
+		switch l.current() {
+		case '\n', eof:
+			return l.errorf("unterminated flow collection")
+		case ',':
+			l.next()
+			l.emit(token.COMMA)
+			if l.flow[len(l.flow)-1] == '{' {
+				return lexFlowKey
+			}
+			return lexValue
+		}
+	}
+
+	switch l.current() {
+	case '[':
+		l.flow = append(l.flow, '[')
+		l.next()
+		l.emit(token.LEFT_BRACKET)
+		return lexValue
+	case '{':
+		l.flow = append(l.flow, '{')
+		l.next()
+		l.emit(token.LEFT_BRACE)
+		return lexFlowKey
+	case ']':
+		return lexFlowClose(l, '[', token.RIGHT_BRACKET)
+	case '}':
+		return lexFlowClose(l, '{', token.RIGHT_BRACE)
+	}
+
 	if l.current() == '\n' {
 		l.next()
 		l.emit(token.NEW_LINE)
 		return lexNewLine
-	} else {
-		l.next()
-		l.ignore()
 	}
 
 	switch b := l.current(); {
-	case isNumeric(b):
+	case isNumeric(b), l.lookingAt("inf"), l.lookingAt("nan"):
 		return lexNumber
 	case b == '"':
 		return lexString
+	case b == '`':
+		return lexRawString
 	case b == 't' || b == 'f':
 		// boolean
 		return lexBool
+	case b == 'n':
+		return lexNull
 	case b == '\n':
 		return lexNewLine
 	case b == eof:
@@ -313,7 +684,58 @@ func lexValue(l *lexer) stateFn {
 		return nil
 	}
 
-	return l.errorf("unknown identifier '%s'", []byte{l.current()})
+	return l.errorf("unknown identifier '%s'", string(l.current()))
+}
+
+// lexFlowClose closes the innermost flow collection, which must have been
+// opened with 'open'
+func lexFlowClose(l *lexer, open byte, tokenType token.TokenType) stateFn {
+	if len(l.flow) == 0 || l.flow[len(l.flow)-1] != open {
+		return l.errorf("unexpected '%s'", string(l.current()))
+	}
+
+	l.flow = l.flow[:len(l.flow)-1]
+	l.next()
+	l.emit(tokenType)
+	return lexValue
+}
+
+// lexFlowKey lexes the key of an entry in a flow map, e.g. the 'a' in
+// '{a: 1}'
+func lexFlowKey(l *lexer) stateFn {
+	if l.Verbose {
+		log.Println("===== lexFlowKey")
+	}
+
+	if l.current() == ' ' || l.current() == '\t' {
+		l.acceptRun(" \t")
+		l.next()
+		l.ignore()
+	}
+
+	switch b := l.current(); {
+	case isLetter(b):
+		return lexIdentifier
+	case b == '"':
+		return lexQuotedKey
+	case b == '}':
+		// empty map
+		return lexValue
+	}
+
+	return l.errorf("expected key in flow map, got '%s'", string(l.current()))
+}
+
+func lexNull(l *lexer) stateFn {
+	for _, valid := range []string{"u", "l", "l"} {
+		if l.accept(valid) == false {
+			return l.errorf("invalid null value (expected 'null')")
+		}
+	}
+
+	l.next()
+	l.emit(token.NULL)
+	return lexValue
 }
 
 func lexBool(l *lexer) stateFn {
@@ -356,56 +778,513 @@ func lexBool(l *lexer) stateFn {
 	return nil
 }
 
+// lexString lexes a quoted string. The literal is the string without its
+// quotes and with its escape sequences decoded.
 func lexString(l *lexer) stateFn {
 	if l.Verbose {
 		log.Println("===== lexString")
 	}
 
-	// ignore the first "
+	if l.lookingAt(`"""`) {
+		return lexMultilineString
+	}
+
+	return lexQuoted(l, token.STRING, lexValue)
+}
+
+// lexQuoted lexes a string in double quotes and emits it as tokenType,
+// without its quotes and with its escape sequences decoded
+func lexQuoted(l *lexer, tokenType token.TokenType, next stateFn) stateFn {
+	var b strings.Builder
+
+	// skip the first "
 	l.next()
-	l.ignore()
 
 LOOP:
 	for {
-		switch l.next() {
+		switch r := l.current(); r {
 		case '\\':
-			if r := l.next(); r != eof && r != '\n' {
-				break
+			r, err := lexEscape(l)
+			if err != "" {
+				return l.errorf("%s", err)
 			}
-			fallthrough
-
+			b.WriteRune(r)
 		case eof, '\n':
 			return l.errorf("unterminated quoted string")
+		case invalid:
+			l.moveStart(l.position)
+			return l.errorf("invalid UTF-8 encoding in string")
 		case '"':
 			break LOOP
+		default:
+			b.WriteRune(r)
+			l.next()
+		}
+	}
+
+	l.next()
+	l.emitLiteral(tokenType, b.String())
+
+	return next
+}
+
+// lexMultilineString lexes a string in triple quotes, which starts on the
+// line after the opening quotes and ends at the line with the closing quotes.
+// The lines must be indented one level more than the line of the key (or
+// dash) they belong to, and that indentation is not part of the string. The
+// new line before the closing quotes isn't either.
+func lexMultilineString(l *lexer) stateFn {
+	if l.Verbose {
+		log.Println("===== lexMultilineString")
+	}
+
+	if len(l.flow) > 0 {
+		return l.errorf("multi-line string in flow collection")
+	}
+
+	l.position += len(`"""`)
+	for r := l.current(); r == ' ' || r == '\t'; r = l.next() {
+	}
+	if l.current() != '\n' {
+		return l.errorf("expected new line after '\"\"\"'")
+	}
+	l.next()
+
+	// One level of indentation, guessed from the string itself if no line
+	// has been indented yet
+	unit := "\t"
+	if l.indent == ' ' || (l.indent == 0 && l.current() == ' ') {
+		width := l.IndentWidth
+		if width == 0 {
+			for l.lookingAt(strings.Repeat(" ", width+1)) {
+				width += 1
+			}
 		}
+		unit = strings.Repeat(" ", width)
 	}
+	prefix := strings.Repeat(unit, l.levels+1)
+
+	var b strings.Builder
+	for lines := 0; ; lines++ {
+		lineStart := l.position
+
+		for r := l.current(); r == ' ' || r == '\t'; r = l.next() {
+		}
+		if l.lookingAt(`"""`) {
+			l.position += len(`"""`)
+			break
+		}
+		if l.current() == eof {
+			return l.errorf("unterminated multi-line string")
+		}
 
-	l.emit(token.STRING)
+		if lines > 0 {
+			b.WriteByte('\n')
+		}
+
+		// lines with nothing but whitespace are empty
+		if l.current() == '\n' {
+			l.next()
+			continue
+		}
 
-	// ignore last "
+		l.position = lineStart
+		if l.lookingAt(prefix) == false {
+			l.moveStart(lineStart)
+			return l.errorf("lines of a multi-line string must be indented one level more than its key")
+		}
+		l.position += len(prefix)
+
+	LINE:
+		for {
+			switch r := l.current(); r {
+			case '\\':
+				r, err := lexEscape(l)
+				if err != "" {
+					return l.errorf("%s", err)
+				}
+				b.WriteRune(r)
+			case '\n':
+				l.next()
+				break LINE
+			case eof:
+				return l.errorf("unterminated multi-line string")
+			case invalid:
+				l.moveStart(l.position)
+				return l.errorf("invalid UTF-8 encoding in string")
+			default:
+				b.WriteRune(r)
+				l.next()
+			}
+		}
+	}
+
+	l.emitLiteral(token.STRING, b.String())
+	return lexValue
+}
+
+// lexRawString lexes a string in backticks. Everything up to the closing
+// backtick is part of the string, new lines included, and backslashes have
+// no special meaning.
+func lexRawString(l *lexer) stateFn {
+	if l.Verbose {
+		log.Println("===== lexRawString")
+	}
+
+	// skip the first `
 	l.next()
-	l.ignore()
+
+LOOP:
+	for {
+		switch l.current() {
+		case eof:
+			return l.errorf("unterminated raw string")
+		case invalid:
+			l.moveStart(l.position)
+			return l.errorf("invalid UTF-8 encoding in string")
+		case '`':
+			break LOOP
+		default:
+			l.next()
+		}
+	}
+
+	l.next()
+	text := l.text()
+	l.emitLiteral(token.STRING, text[1:len(text)-1])
 
 	return lexValue
 }
 
+// lexEscape decodes the escape sequence at the current position, and moves
+// past it. If the sequence is invalid, the start of the token is moved to it
+// and the message for the error is returned.
+func lexEscape(l *lexer) (rune, string) {
+	start := l.position
+
+	digits := 0
+	switch r := l.next(); r {
+	case 'n':
+		l.next()
+		return '\n', ""
+	case 't':
+		l.next()
+		return '\t', ""
+	case '"', '\\':
+		l.next()
+		return r, ""
+	case 'u':
+		digits = 4
+	case 'U':
+		digits = 8
+	case eof, '\n':
+		return 0, "unterminated quoted string"
+	default:
+		l.moveStart(start)
+		return 0, fmt.Sprintf("invalid escape sequence '\\%s'", string(r))
+	}
+
+	value := rune(0)
+	for i := 0; i < digits; i++ {
+		r := l.next()
+		d, ok := hexValue(r)
+		if ok == false {
+			l.moveStart(start)
+			return 0, fmt.Sprintf("invalid escape sequence '%s', expected %d hex digits", l.text(), digits)
+		}
+		value = value*16 + d
+	}
+	l.next()
+
+	if utf8.ValidRune(value) == false {
+		l.moveStart(start)
+		return 0, fmt.Sprintf("escape sequence '%s' is not a valid code point", l.text())
+	}
+
+	return value, ""
+}
+
+// lexNumber lexes an integer or a float. Integers are decimal, or hex, octal
+// or binary with a 0x, 0o or 0b prefix. A leading zero also makes an integer
+// octal, as in 0755. Floats have a fraction, an exponent or both, or are one
+// of inf and nan. Underscores may only be used between two digits. A decimal
+// number followed by a unit is a duration or a byte size.
 func lexNumber(l *lexer) stateFn {
 	if l.Verbose {
 		log.Println("===== lexNumber")
 	}
-	l.accept("+-")
-	l.acceptRun("0123456789_")
-	if l.accept(".") {
-		l.acceptRun("0123456789_")
+
+	switch {
+	case l.lookingAtPattern("dddd-dd-dd"):
+		return lexDate
+	case l.lookingAtPattern("dd:dd:dd"):
+		return lexTime
+	}
+
+	if l.current() == '+' || l.current() == '-' {
 		l.next()
-		l.emit(token.FLOAT)
-	} else {
+	}
+
+	for _, word := range []string{"inf", "nan"} {
+		if l.lookingAt(word) {
+			l.position += len(word)
+			return endNumber(l, token.FLOAT)
+		}
+	}
+
+	if l.current() == '0' {
+		base, valid := "", isDigit
+		switch l.peek() {
+		case 'x', 'X':
+			base, valid = "hex", isHex
+		case 'o', 'O':
+			base, valid = "octal", isOctal
+		case 'b', 'B':
+			base, valid = "binary", isBinary
+		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', '_':
+			base, valid = "octal", isOctal
+			if isUnitAfterDigits(l) {
+				base = ""
+			}
+		}
+
+		// a prefix is only a prefix with a digit after it, so that 0B and
+		// 0s are read with their unit
+		if base != "" && l.peek() > '9' {
+			position := l.position
+			l.next()
+			l.next()
+			if valid(l.current()) == false {
+				l.position = position
+				base = ""
+			}
+		}
+
+		if base != "" {
+			if err := lexDigits(l, valid, base+" digit"); err != "" {
+				return l.errorf("%s", err)
+			}
+			if isDigit(l.current()) || isLetter(l.current()) {
+				l.moveStart(l.position)
+				return l.errorf("invalid digit '%s' in %s number", string(l.current()), base)
+			}
+			return endNumber(l, token.INT)
+		}
+	}
+
+	if err := lexDigits(l, isDigit, "digit"); err != "" {
+		return l.errorf("%s", err)
+	}
+
+	tokenType := token.INT
+	if l.current() == '.' {
+		tokenType = token.FLOAT
 		l.next()
-		l.emit(token.INT)
+		if err := lexDigits(l, isDigit, "digit after '.'"); err != "" {
+			return l.errorf("%s", err)
+		}
 	}
 
-	return lexValue
+	if isExponent(l) {
+		tokenType = token.FLOAT
+		l.next()
+		if l.current() == '+' || l.current() == '-' {
+			l.next()
+		}
+		if err := lexDigits(l, isDigit, "digit in exponent"); err != "" {
+			return l.errorf("%s", err)
+		}
+	} else if isUnit(l.current()) {
+		return lexUnit(l)
+	}
+
+	return endNumber(l, tokenType)
+}
+
+// isExponent reports whether the input at the current position is the start
+// of an exponent, as opposed to a unit that starts with 'e'
+func isExponent(l *lexer) bool {
+	if l.current() != 'e' && l.current() != 'E' {
+		return false
+	}
+
+	position := l.position
+	l.next()
+	if l.current() == '+' || l.current() == '-' {
+		l.next()
+	}
+	ok := isDigit(l.current())
+	l.position = position
+
+	return ok
+}
+
+// isUnitAfterDigits reports whether the digits at the current position are
+// followed by a unit, like in 0755s
+func isUnitAfterDigits(l *lexer) bool {
+	position := l.position
+	for isDigit(l.current()) || l.current() == '_' {
+		l.next()
+	}
+	ok := isUnit(l.current()) && isExponent(l) == false
+	l.position = position
+
+	return ok
+}
+
+// lexUnit lexes the unit after a number, which makes it a byte size like
+// 512KiB, or a duration like 1h30m where the number and unit can be repeated
+func lexUnit(l *lexer) stateFn {
+	start := l.position
+	for isUnit(l.current()) {
+		l.next()
+	}
+	unit := l.text()[start-l.start:]
+
+	if ast.IsByteUnit(unit) {
+		return endNumber(l, token.BYTE_SIZE)
+	}
+
+	for {
+		if isDurationUnit(unit) == false {
+			l.moveStart(start)
+			return l.errorf("unknown unit '%s'", unit)
+		}
+
+		if isDigit(l.current()) == false {
+			return endNumber(l, token.DURATION)
+		}
+
+		if err := lexDigits(l, isDigit, "digit"); err != "" {
+			return l.errorf("%s", err)
+		}
+		if l.current() == '.' {
+			l.next()
+			if err := lexDigits(l, isDigit, "digit after '.'"); err != "" {
+				return l.errorf("%s", err)
+			}
+		}
+
+		start = l.position
+		for isUnit(l.current()) {
+			l.next()
+		}
+		unit = l.text()[start-l.start:]
+		if unit == "" {
+			l.moveStart(start)
+			return l.errorf("expected unit, got %s", describe(l.current()))
+		}
+	}
+}
+
+// lexDate lexes a date like 2024-01-15, or a datetime like
+// 2024-01-15T10:30:00Z, which must have a time zone offset
+func lexDate(l *lexer) stateFn {
+	l.position += len("2006-01-02")
+	if l.current() != 'T' && l.current() != 't' {
+		return endNumber(l, token.DATE)
+	}
+
+	l.next()
+	if err := lexClock(l); err != "" {
+		return l.errorf("%s", err)
+	}
+
+	switch {
+	case l.current() == 'Z' || l.current() == 'z':
+		l.next()
+	case l.lookingAtPattern("+dd:dd") || l.lookingAtPattern("-dd:dd"):
+		l.position += len("+07:00")
+	default:
+		l.moveStart(l.position)
+		return l.errorf("expected time zone offset like Z or +01:00, got %s", describe(l.current()))
+	}
+
+	return endNumber(l, token.DATETIME)
+}
+
+// lexTime lexes a time of day like 10:30:00
+func lexTime(l *lexer) stateFn {
+	if err := lexClock(l); err != "" {
+		return l.errorf("%s", err)
+	}
+
+	return endNumber(l, token.TIME)
+}
+
+// lexClock consumes a time of day, with an optional fraction of a second. On
+// failure the start is moved to the offending rune and the error message is
+// returned.
+func lexClock(l *lexer) string {
+	if l.lookingAtPattern("dd:dd:dd") == false {
+		l.moveStart(l.position)
+		return fmt.Sprintf("expected time like 10:30:00, got %s", describe(l.current()))
+	}
+
+	l.position += len("15:04:05")
+	if l.current() == '.' {
+		l.next()
+		if isDigit(l.current()) == false {
+			l.moveStart(l.position)
+			return fmt.Sprintf("expected digit after '.', got %s", describe(l.current()))
+		}
+		for isDigit(l.current()) {
+			l.next()
+		}
+	}
+
+	return ""
+}
+
+// lexDigits consumes a run of at least one digit, where an underscore may
+// separate two digits. On failure the start is moved to the offending rune
+// and the error message is returned.
+func lexDigits(l *lexer, valid func(rune) bool, expected string) string {
+	if valid(l.current()) == false {
+		l.moveStart(l.position)
+		return fmt.Sprintf("expected %s, got %s", expected, describe(l.current()))
+	}
+
+	for {
+		switch {
+		case valid(l.current()):
+			l.next()
+		case l.current() == '_':
+			if valid(l.peek()) == false {
+				l.moveStart(l.position)
+				return "'_' must separate digits"
+			}
+			l.next()
+		default:
+			return ""
+		}
+	}
+}
+
+// endNumber emits the number, or other value that starts with a digit, which
+// must be followed by whitespace, the end of the line or the end of a flow
+// collection
+func endNumber(l *lexer, tokenType token.TokenType) stateFn {
+	switch l.current() {
+	case ' ', '\t', '\n', eof, ',', ']', '}':
+		l.emit(tokenType)
+		return lexValue
+	}
+
+	names := map[token.TokenType]string{
+		token.DURATION:  "duration",
+		token.BYTE_SIZE: "byte size",
+		token.DATE:      "date",
+		token.TIME:      "time",
+		token.DATETIME:  "datetime",
+	}
+	name, ok := names[tokenType]
+	if ok == false {
+		name = "number"
+	}
+
+	l.moveStart(l.position)
+	return l.errorf("invalid character %s in %s", describe(l.current()), name)
 }
 
 // ==================================================
@@ -414,27 +1293,69 @@ func lexNumber(l *lexer) stateFn {
 //
 // ==================================================
 
-func isLetter(ch byte) bool {
+func isLetter(ch rune) bool {
 	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
 }
 
-func isNumeric(ch byte) bool {
+// hexValue returns the value of a hex digit
+func hexValue(ch rune) (rune, bool) {
 	switch {
-	case ch == '+':
-		return true
-	case ch == '-':
-		return true
-	case ch == '.':
-		return true
-	case ch == '_':
-		return true
 	case '0' <= ch && ch <= '9':
-		return true
+		return ch - '0', true
+	case 'a' <= ch && ch <= 'f':
+		return ch - 'a' + 10, true
+	case 'A' <= ch && ch <= 'F':
+		return ch - 'A' + 10, true
 	}
+	return 0, false
+}
+
+func isNumeric(ch rune) bool {
+	return ch == '+' || ch == '-' || isDigit(ch)
+}
+
+// isUnit reports whether ch can be part of the unit of a duration or byte
+// size. 'µ' and 'μ' are both used for micro.
+func isUnit(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == 'µ' || ch == 'μ'
+}
 
+// isDurationUnit reports whether unit is one of the units understood by
+// time.ParseDuration
+func isDurationUnit(unit string) bool {
+	switch unit {
+	case "ns", "us", "µs", "μs", "ms", "s", "m", "h":
+		return true
+	}
 	return false
 }
 
-func byteToString(b byte) string {
-	return string([]byte{b})
+func isDigit(ch rune) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+func isHex(ch rune) bool {
+	_, ok := hexValue(ch)
+	return ok
+}
+
+func isOctal(ch rune) bool {
+	return '0' <= ch && ch <= '7'
+}
+
+func isBinary(ch rune) bool {
+	return ch == '0' || ch == '1'
+}
+
+// describe quotes a rune for an error message
+func describe(ch rune) string {
+	switch ch {
+	case eof:
+		return "end of input"
+	case '\n':
+		return "end of line"
+	case invalid:
+		return "invalid UTF-8"
+	}
+	return fmt.Sprintf("'%s'", string(ch))
 }
@@ -1,36 +1,36 @@
 package lexer
 
 import (
+	"errors"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/doctordesh/yrm/token"
 	check "gitlab.com/MaxIV/lib-maxiv-go-check"
 )
 
 func newLexer(input string) *lexer {
-	return &lexer{
-		input:    input,
-		tokens:   []token.Token{},
-		start:    0,
-		position: 0,
-	}
+	l := New(input)
+	l.tokens = []token.Token{}
+	return l
 }
 
 func TestCurrent(t *testing.T) {
 	l := newLexer("")
 	check.Equals(t, eof, l.current())
 
-	l.input = "a"
-	check.Equals(t, byte('a'), l.current())
+	l = newLexer("a")
+	check.Equals(t, rune('a'), l.current())
 
-	l.input = "abc"
-	check.Equals(t, byte('a'), l.current())
+	l = newLexer("abc")
+	check.Equals(t, rune('a'), l.current())
 
 	l.position = 1
-	check.Equals(t, byte('b'), l.current())
+	check.Equals(t, rune('b'), l.current())
 
 	l.position = 2
-	check.Equals(t, byte('c'), l.current())
+	check.Equals(t, rune('c'), l.current())
 
 	l.position = 3
 	check.Equals(t, eof, l.current())
@@ -48,8 +48,7 @@ func TestEmit(t *testing.T) {
 	check.Equals(t, token.EOF, tok.TokenType)
 	check.Equals(t, "", tok.Literal)
 
-	l.input = ":"
-	l.start = 0
+	l = newLexer(":")
 	l.position = 1
 	l.emit(token.COLON_SIGN)
 	tok, err = l.nextToken()
@@ -59,8 +58,9 @@ func TestEmit(t *testing.T) {
 	check.Equals(t, 1, l.start)
 	check.Equals(t, l.start, l.position)
 
-	l.input = "  value"
-	l.start = 2
+	l = newLexer("  value")
+	l.position = 2
+	l.ignore()
 	l.position = 7
 	l.emit(token.IDENTIFIER)
 	tok, err = l.nextToken()
@@ -82,13 +82,13 @@ func TestAccept(t *testing.T) {
 	check.Equals(t, b, true)
 	check.Equals(t, 0, l.start)
 	check.Equals(t, 1, l.position)
-	check.Equals(t, byte('8'), l.current())
+	check.Equals(t, rune('8'), l.current())
 
 	b = l.accept(digits)
 	check.Equals(t, b, false)
 	check.Equals(t, 0, l.start)
 	check.Equals(t, 1, l.position)
-	check.Equals(t, byte('8'), l.current())
+	check.Equals(t, rune('8'), l.current())
 }
 
 func TestAcceptRun(t *testing.T) {
@@ -99,7 +99,7 @@ func TestAcceptRun(t *testing.T) {
 	l.acceptRun(digits)
 	check.Equals(t, 0, l.start)
 	check.Equals(t, 3, l.position)
-	check.Equals(t, byte('5'), l.current())
+	check.Equals(t, rune('5'), l.current())
 }
 
 func TestLexColon(t *testing.T) {
@@ -116,8 +116,7 @@ func TestLexColon(t *testing.T) {
 	check.Equals(t, eof, l.current())
 
 	// checking error condition
-	l.input = ""
-	l.start, l.position = 0, 0
+	l = newLexer("")
 	lexColon(l)
 	tok, err = l.nextToken()
 	check.OK(t, err)
@@ -141,9 +140,7 @@ func TestLexNumber(t *testing.T) {
 	check.Equals(t, 3, l.position)
 
 	// int
-	l.start = 0
-	l.position = 0
-	l.input = "15823"
+	l = newLexer("15823")
 
 	lexNumber(l)
 	tok, err = l.nextToken()
@@ -155,6 +152,48 @@ func TestLexNumber(t *testing.T) {
 	check.Equals(t, 5, l.position)
 }
 
+func TestLexNumberLiterals(t *testing.T) {
+	type row struct {
+		Input     string
+		TokenType token.TokenType
+	}
+
+	table := []row{
+		row{Input: "-42", TokenType: token.INT},
+		row{Input: "1_000_000", TokenType: token.INT},
+		row{Input: "0xFF", TokenType: token.INT},
+		row{Input: "0o755", TokenType: token.INT},
+		row{Input: "0755", TokenType: token.INT},
+		row{Input: "0b1010_1010", TokenType: token.INT},
+		row{Input: "0", TokenType: token.INT},
+		row{Input: "+1.5", TokenType: token.FLOAT},
+		row{Input: "1e9", TokenType: token.FLOAT},
+		row{Input: "6.02E-23", TokenType: token.FLOAT},
+		row{Input: "-inf", TokenType: token.FLOAT},
+		row{Input: "nan", TokenType: token.FLOAT},
+		row{Input: "30s", TokenType: token.DURATION},
+		row{Input: "1h30m", TokenType: token.DURATION},
+		row{Input: "00s", TokenType: token.DURATION},
+		row{Input: "0755s", TokenType: token.DURATION},
+		row{Input: "0B", TokenType: token.BYTE_SIZE},
+		row{Input: "0b1", TokenType: token.INT},
+		row{Input: "1.5µs", TokenType: token.DURATION},
+		row{Input: "512KiB", TokenType: token.BYTE_SIZE},
+		row{Input: "2GB", TokenType: token.BYTE_SIZE},
+		row{Input: "2024-01-15", TokenType: token.DATE},
+		row{Input: "10:30:00.5", TokenType: token.TIME},
+		row{Input: "2024-01-15T10:30:00Z", TokenType: token.DATETIME},
+		row{Input: "2024-01-15t10:30:00.123-05:00", TokenType: token.DATETIME},
+	}
+
+	for i, r := range table {
+		tokens, err := New("a: " + r.Input + "\n").Lex()
+		check.OKWithMessage(t, err, "row: %d", i+1)
+		check.EqualsWithMessage(t, r.TokenType, tokens[2].TokenType, "row: %d", i+1)
+		check.EqualsWithMessage(t, r.Input, tokens[2].Literal, "row: %d", i+1)
+	}
+}
+
 func TestLexString(t *testing.T) {
 	input := `"lorem ipsum"`
 	l := newLexer(input)
@@ -167,6 +206,99 @@ func TestLexString(t *testing.T) {
 	check.Equals(t, "lorem ipsum", tok.Literal)
 	check.Equals(t, 13, l.start)
 	check.Equals(t, 13, l.position)
+
+	// empty string
+	l = newLexer(`""`)
+	lexString(l)
+	tok, err = l.nextToken()
+	check.OK(t, err)
+	check.Equals(t, token.STRING, tok.TokenType)
+	check.Equals(t, "", tok.Literal)
+	check.Equals(t, 2, l.position)
+}
+
+func TestLexStringEscape(t *testing.T) {
+	type row struct {
+		Input   string
+		Literal string
+		Error   string
+	}
+
+	table := []row{
+		row{Input: `"a\"b"`, Literal: `a"b`},
+		row{Input: `"\\n\n\t"`, Literal: "\\n\n\t"},
+		row{Input: `"\u00e9\u263A \U0001F600"`, Literal: "é☺ 😀"},
+		row{Input: `"héllo ☺"`, Literal: "héllo ☺"},
+		row{Input: `"a\qb"`, Error: "1:3: invalid escape sequence '\\q'"},
+		row{Input: `"a\u12"`, Error: "1:3: invalid escape sequence '\\u12', expected 4 hex digits"},
+		row{Input: `"\U00110000"`, Error: "1:2: escape sequence '\\U00110000' is not a valid code point"},
+		row{Input: "\"ab\xffc\"", Error: "1:4: invalid UTF-8 encoding in string"},
+		row{Input: `"ab\`, Error: "1:1: unterminated quoted string"},
+	}
+
+	for i, r := range table {
+		tokens, err := New("a: " + r.Input + "\n").Lex()
+		if r.Error != "" {
+			check.NotOKWithMessage(t, err, "row: %d", i+1)
+
+			// the error is at the escape sequence, after 'a: '
+			var lexErr *Error
+			check.Assert(t, errors.As(err, &lexErr))
+			lexErr.Position.Column -= 3
+			check.EqualsWithMessage(t, r.Error, lexErr.Error(), "row: %d", i+1)
+			continue
+		}
+
+		check.OKWithMessage(t, err, "row: %d", i+1)
+		check.EqualsWithMessage(t, token.STRING, tokens[2].TokenType, "row: %d", i+1)
+		check.EqualsWithMessage(t, r.Literal, tokens[2].Literal, "row: %d", i+1)
+	}
+
+	_, err := New("// bad \xff\n").Lex()
+	check.NotOK(t, err)
+	check.Equals(t, "1:8: invalid UTF-8 encoding in comment", err.Error())
+}
+
+func TestLexMultilineString(t *testing.T) {
+	type row struct {
+		Input   string
+		Literal string
+		Error   string
+	}
+
+	table := []row{
+		row{Input: "a: \"\"\"\n\tone\n\t\ttwo \"x\"\n\n\tthree\\t\n\t\"\"\"\n", Literal: "one\n\ttwo \"x\"\n\nthree\t"},
+		row{Input: "a:\n\tb: \"\"\"\n\t\tone\n\t\t\"\"\"\n", Literal: "one"},
+		row{Input: "a:\n    b: \"\"\"\n        one\n          two\n    \"\"\"\n", Literal: "one\n  two"},
+		row{Input: "a: \"\"\"\n  one\n    two\n  \"\"\"\n", Literal: "one\n  two"},
+		row{Input: "a:\n\t- \"\"\"\n\t\tone\n\t\t\"\"\"\n", Literal: "one"},
+		row{Input: "a: \"\"\"\n\"\"\"\n", Literal: ""},
+		row{Input: "a: `C:\\path\n\tline \"two\"`\n", Literal: "C:\\path\n\tline \"two\""},
+		row{Input: "a:\n\tb: \"\"\"\n\tone\n\t\"\"\"\n", Error: "3:1: lines of a multi-line string must be indented one level more than its key"},
+		row{Input: "a: \"\"\" x\n", Error: "1:4: expected new line after '\"\"\"'"},
+		row{Input: "a: \"\"\"\n\tone\n", Error: "1:4: unterminated multi-line string"},
+		row{Input: "a: [\"\"\"\n]\n", Error: "1:5: multi-line string in flow collection"},
+		row{Input: "a: `one\n", Error: "1:4: unterminated raw string"},
+	}
+
+	for i, r := range table {
+		tokens, err := New(r.Input).Lex()
+		if r.Error != "" {
+			check.NotOKWithMessage(t, err, "row: %d", i+1)
+			check.EqualsWithMessage(t, r.Error, err.Error(), "row: %d", i+1)
+			continue
+		}
+
+		check.OKWithMessage(t, err, "row: %d", i+1)
+
+		var literal string
+		for _, tok := range tokens {
+			if tok.TokenType == token.STRING {
+				literal = tok.Literal
+			}
+		}
+		check.EqualsWithMessage(t, r.Literal, literal, "row: %d", i+1)
+	}
 }
 
 func TestLexIdentifier(t *testing.T) {
@@ -188,6 +320,19 @@ func TestLexIdentifier(t *testing.T) {
 	check.Equals(t, tok.Literal, "expected ':'")
 }
 
+func TestLexKeys(t *testing.T) {
+	tokens, err := New("x-request-id: 1\nhttp2: 2\n\"my key\": 3\nm: {\"a\\tb\": 4, v1: 5}\n").Lex()
+	check.OK(t, err)
+
+	var keys []string
+	for _, tok := range tokens {
+		if tok.TokenType == token.IDENTIFIER {
+			keys = append(keys, tok.Literal)
+		}
+	}
+	check.Equals(t, []string{"x-request-id", "http2", "my key", "m", "a\tb", "v1"}, keys)
+}
+
 func TestLexComment(t *testing.T) {
 	var tok token.Token
 	var err error
@@ -203,8 +348,7 @@ func TestLexComment(t *testing.T) {
 	check.OK(t, err)
 	check.Equals(t, token.NEW_LINE, tok.TokenType)
 
-	l.input = "// some comment" // not, no new line
-	l.start, l.position = 0, 0
+	l = newLexer("// some comment") // not, no new line
 	lexComment(l)
 	tok, err = l.nextToken()
 	check.OK(t, err)
@@ -215,6 +359,241 @@ func TestLexComment(t *testing.T) {
 	check.Equals(t, token.EOF, tok.TokenType)
 }
 
+func TestLexDash(t *testing.T) {
+	var tok token.Token
+	var err error
+	var l *lexer
+
+	l = newLexer("- 5")
+	l.startState = lexNewLine
+	_, err = l.Lex()
+	check.OK(t, err)
+
+	tok, err = l.nextToken()
+	check.OK(t, err)
+	check.Equals(t, token.DASH, tok.TokenType)
+	check.Equals(t, "-", tok.Literal)
+
+	tok, err = l.nextToken()
+	check.OK(t, err)
+	check.Equals(t, token.INT, tok.TokenType)
+	check.Equals(t, "5", tok.Literal)
+
+	// a dash must be followed by whitespace
+	l = newLexer("-5")
+	lexDash(l)
+	tok, err = l.nextToken()
+	check.OK(t, err)
+	check.Equals(t, token.ILLEGAL, tok.TokenType)
+}
+
+func TestLexFlow(t *testing.T) {
+	var tok token.Token
+	var err error
+	var l *lexer
+
+	l = newLexer("a: [1, {b: \"c\"}, []]\n")
+	l.startState = lexIdentifier
+	_, err = l.Lex()
+	check.OK(t, err)
+
+	expected := []token.TokenType{
+		token.IDENTIFIER,
+		token.COLON_SIGN,
+		token.LEFT_BRACKET,
+		token.INT,
+		token.COMMA,
+		token.LEFT_BRACE,
+		token.IDENTIFIER,
+		token.COLON_SIGN,
+		token.STRING,
+		token.RIGHT_BRACE,
+		token.COMMA,
+		token.LEFT_BRACKET,
+		token.RIGHT_BRACKET,
+		token.RIGHT_BRACKET,
+		token.NEW_LINE,
+		token.EOF,
+	}
+	for i := range expected {
+		tok, err = l.nextToken()
+		check.OK(t, err)
+		check.EqualsWithMessage(t, expected[i], tok.TokenType, "token: %d", i+1)
+	}
+
+	// flow collections must be closed on the same line
+	l = newLexer("a: [1, 2\n")
+	l.startState = lexIdentifier
+	_, err = l.Lex()
+	check.NotOK(t, err)
+	check.Equals(t, token.ILLEGAL, l.tokens[len(l.tokens)-1].TokenType)
+
+	// closing something that is not open
+	l = newLexer("a: [1, 2}\n")
+	l.startState = lexIdentifier
+	_, err = l.Lex()
+	check.NotOK(t, err)
+	check.Equals(t, token.ILLEGAL, l.tokens[len(l.tokens)-1].TokenType)
+}
+
+func TestLexPosition(t *testing.T) {
+	var tok token.Token
+	var err error
+
+	l := New("foo: 5\nbar:\n\tbaz: \"x\"\n")
+	l.Filename = "test.yrm"
+	_, err = l.Lex()
+	check.OK(t, err)
+
+	expected := []token.Position{
+		{Filename: "test.yrm", Offset: 0, Line: 1, Column: 1},   // foo
+		{Filename: "test.yrm", Offset: 3, Line: 1, Column: 4},   // :
+		{Filename: "test.yrm", Offset: 5, Line: 1, Column: 6},   // 5
+		{Filename: "test.yrm", Offset: 6, Line: 1, Column: 7},   // \n
+		{Filename: "test.yrm", Offset: 7, Line: 2, Column: 1},   // bar
+		{Filename: "test.yrm", Offset: 10, Line: 2, Column: 4},  // :
+		{Filename: "test.yrm", Offset: 11, Line: 2, Column: 5},  // \n
+		{Filename: "test.yrm", Offset: 12, Line: 3, Column: 1},  // \t
+		{Filename: "test.yrm", Offset: 13, Line: 3, Column: 2},  // baz
+		{Filename: "test.yrm", Offset: 16, Line: 3, Column: 5},  // :
+		{Filename: "test.yrm", Offset: 18, Line: 3, Column: 7},  // "x"
+		{Filename: "test.yrm", Offset: 21, Line: 3, Column: 10}, // \n
+		{Filename: "test.yrm", Offset: 22, Line: 4, Column: 1},  // EOF
+	}
+	for i := range expected {
+		tok, err = l.nextToken()
+		check.OK(t, err)
+		check.EqualsWithMessage(t, expected[i], tok.Position, "token: %d", i+1)
+	}
+
+	check.Equals(t, "test.yrm:3:7", expected[10].String())
+}
+
+func TestLexError(t *testing.T) {
+	type row struct {
+		Input string
+		Error string
+	}
+
+	table := []row{
+		row{Input: "foo: 5\nbar: \"lorem\n", Error: "2:6: unterminated quoted string"},
+		row{Input: "foo: tru\n", Error: "1:6: invalid boolean value (expected 'true')"},
+		row{Input: "foo: nul\n", Error: "1:6: invalid null value (expected 'null')"},
+		row{Input: "foo:\n\t5: 1\n", Error: "2:2: unexpected character '5' at start of line"},
+		row{Input: "foo: 5 $\n", Error: "1:8: unknown identifier '$'"},
+		row{Input: "foo: 1_._\n", Error: "1:7: '_' must separate digits"},
+		row{Input: "foo: 1__0\n", Error: "1:7: '_' must separate digits"},
+		row{Input: "foo: 0x\n", Error: "1:7: unknown unit 'x'"},
+		row{Input: "foo: 0xG\n", Error: "1:7: unknown unit 'xG'"},
+		row{Input: "foo: 0b102\n", Error: "1:10: invalid digit '2' in binary number"},
+		row{Input: "foo: 089\n", Error: "1:7: invalid digit '8' in octal number"},
+		row{Input: "foo: 1.\n", Error: "1:8: expected digit after '.', got end of line"},
+		row{Input: "foo: 1e\n", Error: "1:7: unknown unit 'e'"},
+		row{Input: "foo: 12$\n", Error: "1:8: invalid character '$' in number"},
+		row{Input: "foo: 1h5\n", Error: "1:9: expected unit, got end of line"},
+		row{Input: "foo: 1h5KiB\n", Error: "1:9: unknown unit 'KiB'"},
+		row{Input: "foo: 2024-01-15T10:30:00\n", Error: "1:25: expected time zone offset like Z or +01:00, got end of line"},
+		row{Input: "foo: 2024-01-15T10:30\n", Error: "1:17: expected time like 10:30:00, got '1'"},
+		row{Input: "foo: 10:30:00Z\n", Error: "1:14: invalid character 'Z' in time"},
+		row{Input: "\"foo: 1\n", Error: "1:1: unterminated quoted string"},
+		row{Input: "\"\"\"foo\"\"\": 1\n", Error: "1:1: a key can't be a multi-line string"},
+		row{Input: "1foo: 1\n", Error: "1:1: unexpected character '1' at start of line"},
+	}
+
+	for i := range table {
+		l := New(table[i].Input)
+		tokens, err := l.Lex()
+		check.NotOKWithMessage(t, err, "row: %d", i+1)
+		check.EqualsWithMessage(t, table[i].Error, err.Error(), "row: %d", i+1)
+		check.EqualsWithMessage(t, token.ILLEGAL, tokens[len(tokens)-1].TokenType, "row: %d", i+1)
+	}
+}
+
+func TestLexIndent(t *testing.T) {
+	type row struct {
+		Input string
+		Width int
+		Tabs  []int // number of TAB tokens on each line
+		Error string
+	}
+
+	table := []row{
+		row{Input: "a:\n\tb:\n\t\tc: 1\n", Tabs: []int{0, 1, 2}},
+		row{Input: "a:\n  b:\n    c: 1\n", Tabs: []int{0, 1, 2}},
+		row{Input: "a:\n    b:\n        c: 1\n", Tabs: []int{0, 1, 2}},
+		row{Input: "a:\n    b:\n        c: 1\n", Width: 2, Tabs: []int{0, 2, 4}},
+		row{Input: "a:\n  \n  b: 1\n   // comment\n", Tabs: []int{0, 0, 1, 0}},
+		row{Input: "a:\n\t b: 1\n", Error: "2:2: indentation mixes tabs and spaces"},
+		row{Input: "a:\n  \tb: 1\n", Error: "2:3: indentation mixes tabs and spaces"},
+		row{Input: "a:\n\tb: 1\nc:\n  d: 1\n", Error: "4:1: indented with spaces, but line 2 is indented with tabs"},
+		row{Input: "a:\n  b:\n     c: 1\n", Error: "3:1: indentation of 5 spaces is not a multiple of 2"},
+	}
+
+	for i, r := range table {
+		l := New(r.Input)
+		l.IndentWidth = r.Width
+		tokens, err := l.Lex()
+
+		if r.Error != "" {
+			check.NotOKWithMessage(t, err, "row: %d", i+1)
+			check.EqualsWithMessage(t, r.Error, err.Error(), "row: %d", i+1)
+			continue
+		}
+
+		check.OKWithMessage(t, err, "row: %d", i+1)
+
+		tabs := []int{0}
+		for _, tok := range tokens {
+			switch tok.TokenType {
+			case token.TAB:
+				tabs[len(tabs)-1] += 1
+			case token.NEW_LINE:
+				tabs = append(tabs, 0)
+			}
+		}
+		check.EqualsWithMessage(t, r.Tabs, tabs[:len(tabs)-1], "row: %d", i+1)
+	}
+}
+
+func TestNext(t *testing.T) {
+	var tok token.Token
+	var err error
+
+	input := strings.Repeat("foo:\n\tbar: \"lorem ipsum\"\n", 1000)
+	l := NewReader(iotest.OneByteReader(strings.NewReader(input)))
+
+	n := 0
+	for {
+		tok, err = l.Next()
+		check.OK(t, err)
+
+		// nothing but the current token is kept around
+		check.Assert(t, len(l.buffer) <= len("lorem ipsum"))
+		check.Assert(t, len(l.tokens) <= 1)
+
+		n += 1
+		if tok.TokenType == token.EOF {
+			break
+		}
+	}
+
+	check.Equals(t, 1000*8+1, n)
+	check.Equals(t, 2001, tok.Line)
+
+	// keeps returning the last token
+	tok, err = l.Next()
+	check.OK(t, err)
+	check.Equals(t, token.EOF, tok.TokenType)
+
+	l = New("foo: tru\n")
+	for i := 0; i < 3; i++ {
+		tok, err = l.Next()
+	}
+	check.NotOK(t, err)
+	check.Equals(t, token.ILLEGAL, tok.TokenType)
+	check.Equals(t, "1:6: invalid boolean value (expected 'true')", err.Error())
+}
+
 func TestLexNewLine(t *testing.T) {
 	var tok token.Token
 	var err error
@@ -228,12 +607,19 @@ func TestLexNewLine(t *testing.T) {
 	check.Equals(t, token.NEW_LINE, tok.TokenType)
 
 	// tab input
-	l = newLexer("\t")
+	l = newLexer("\ta")
 	lexNewLine(l)
 	tok, err = l.nextToken()
 	check.OK(t, err)
 	check.Equals(t, token.TAB, tok.TokenType)
 
+	// the indentation of a blank line is dropped
+	l = newLexer("\t\t\n")
+	lexNewLine(l)(l)
+	tok, err = l.nextToken()
+	check.OK(t, err)
+	check.Equals(t, token.NEW_LINE, tok.TokenType)
+
 	// empty input
 	l = newLexer("")
 	lexNewLine(l)
@@ -0,0 +1,62 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/doctordesh/yrm/token"
+)
+
+// SyntaxError is returned when the input does not follow the grammar, e.g. an
+// unexpected token or an unterminated string
+type SyntaxError struct {
+	Position token.Position
+	Path     []string    // path to the value being parsed
+	Token    token.Token // the offending token
+	Msg      string
+}
+
+func (self *SyntaxError) Error() string {
+	return fmt.Sprintf("%s: %s", self.Position, self.Msg)
+}
+
+// DuplicateKeyError is returned when a key appears twice in the same map
+type DuplicateKeyError struct {
+	Position token.Position
+	Path     []string    // path to the duplicate key, including the key
+	Token    token.Token // the second occurrence of the key
+	Key      string
+}
+
+func (self *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("%s: duplicate key '%s'", self.Position, strings.Join(self.Path, "."))
+}
+
+// IndentationError is returned when a line does not have the number of tabs
+// that its place in the structure requires
+type IndentationError struct {
+	Position token.Position
+	Path     []string    // path to the map or list that the line belongs to
+	Token    token.Token // the first token on the line
+	Expected int
+	Got      int
+}
+
+func (self *IndentationError) Error() string {
+	return fmt.Sprintf("%s: expected %d levels of indentation, got %d", self.Position, self.Expected, self.Got)
+}
+
+// SourceError is returned when the token source fails, e.g. on an illegal
+// token found by the lexer
+type SourceError struct {
+	Path []string // path to the value being parsed when the error was found
+	Err  error
+}
+
+func (self *SourceError) Error() string {
+	return self.Err.Error()
+}
+
+func (self *SourceError) Unwrap() error {
+	return self.Err
+}
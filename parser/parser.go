@@ -1,55 +1,95 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/doctordesh/yrm/ast"
 	"github.com/doctordesh/yrm/token"
 )
 
+// TokenSource produces tokens one at a time, e.g. a lexer reading from a
+// stream. After the last token it keeps returning the last token.
+type TokenSource interface {
+	Next() (token.Token, error)
+}
+
 type parser struct {
 	tokens   []token.Token
 	position int
+	path     []string // keys and list indices leading to the current value
+
+	comments []*ast.CommentGroup // every comment group so far
+	doc      *ast.CommentGroup   // last comment group, until it's used as doc
+
+	source  TokenSource // nil when all tokens are given up front
+	err     error       // first error from source
+	errPath []string    // path of the value being parsed when err was found
 }
 
+// New returns a parser for a complete list of tokens, ending with EOF
 func New(tokens []token.Token) *parser {
 	return &parser{tokens: tokens}
 }
 
-// Parse parses a list of tokens into a key-value map
+// NewStream returns a parser that pulls tokens from the source as it needs
+// them, and lets go of them once they have been parsed
+func NewStream(source TokenSource) *parser {
+	return &parser{source: source}
+}
+
+// Parse parses a list of tokens into a key-value map. Errors from the token
+// source are returned as a *SourceError.
 func (self *parser) Parse() (map[string]interface{}, error) {
-	return self.parse(0)
+	file, err := self.ParseAST()
+	if err != nil {
+		return nil, err
+	}
+
+	return ast.Value(file).(map[string]interface{}), nil
+}
+
+// ParseAST parses a list of tokens into a syntax tree. Errors from the token
+// source are returned as a *SourceError.
+func (self *parser) ParseAST() (*ast.File, error) {
+	m, err := self.parse(0)
+	if self.err != nil {
+		return nil, &SourceError{Path: self.errPath, Err: self.err}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	file := &ast.File{
+		Map:      m,
+		Comments: self.comments,
+		EOF:      self.current().Position,
+	}
+
+	return file, nil
 }
 
 // parse
-func (self *parser) parse(depth int) (map[string]interface{}, error) {
-	var v map[string]interface{}
+func (self *parser) parse(depth int) (*ast.MapNode, error) {
+	var v *ast.MapNode
 	var err error
-	var identifier, next token.Token
+	var identifier, colon, next token.Token
 
-	v = make(map[string]interface{})
+	v = &ast.MapNode{}
 
 	// Each iteration in the loop is expected to parse one line with actual
 	// configuration (comments does not count)
 	for {
-		// Consume all new lines and comments (if there are any)
-		for {
-			if self.current().TokenType == token.NEW_LINE {
-				self.next()
-				continue
-			}
-
-			if self.current().TokenType == token.COMMENT {
-				self.next()
-				err = self.expect(token.NEW_LINE)
-				if err != nil {
-					return v, err
-				}
+		self.release()
 
-				continue
-			}
-
-			break
+		// Consume all new lines and comments (if there are any)
+		err = self.skipEmptyLines(true)
+		if err != nil {
+			return v, err
 		}
 
 		// End condition
@@ -62,15 +102,15 @@ func (self *parser) parse(depth int) (map[string]interface{}, error) {
 
 		// too many tabs
 		if t > depth {
-			return v, fmt.Errorf("expected %d tabs, got %d tabs", depth, t)
+			return v, self.indentationError(depth, t)
 		}
 
 		// Less tabs than expected
 		if t < depth {
 			// this means that we're 'moving up' without any values
 			// in the nested object. This is not allowed.
-			if len(v) == 0 {
-				return v, fmt.Errorf("incomplete nested structure")
+			if len(v.Entries) == 0 {
+				return v, self.syntaxError(self.current(), "incomplete nested structure")
 			}
 
 			// we're 'moving up'
@@ -82,17 +122,17 @@ func (self *parser) parse(depth int) (map[string]interface{}, error) {
 		// might be zero).
 
 		if self.consumeN(token.TAB, depth) == false {
-			return v, fmt.Errorf("expected")
+			return v, self.indentationError(depth, self.count(token.TAB))
 		}
 
 		// New line starts with identifier
 		err = self.expect(token.IDENTIFIER)
 		if err != nil {
-			return v, fmt.Errorf("expected identifier: %w", err)
+			return v, err
 		}
 
 		identifier = self.current()
-		self.next()
+		colon = self.next()
 
 		// ... and then a colon
 		err = self.expect(token.COLON_SIGN)
@@ -100,58 +140,426 @@ func (self *parser) parse(depth int) (map[string]interface{}, error) {
 			return v, err
 		}
 
+		entry := &ast.KeyValue{
+			Doc:   self.docFor(identifier),
+			Key:   identifier,
+			Colon: colon,
+		}
+
 		// After identifier there is either a value or a new line
-		// (nested object).
+		// (nested object or list).
+		self.push(identifier.Literal)
 		next = self.next()
 		if next.TokenType == token.NEW_LINE {
-			sub, err := self.parse(depth + 1)
+			sub, err := self.parseNested(depth + 1)
 			if err != nil {
-				return v, fmt.Errorf("error further down: %w", err)
+				return v, err
 			}
 
-			if len(sub) == 0 {
-				return v, fmt.Errorf("unfinished nested structure")
+			// Make sure we're not overwriting an existing key
+			if v.Lookup(identifier.Literal) != nil {
+				return v, self.duplicateKeyError(identifier)
 			}
 
+			entry.Value = sub
+		} else if isValue(next) {
+
 			// Make sure we're not overwriting an existing key
-			if _, ok := v[identifier.Literal]; ok {
-				return v, fmt.Errorf("duplicate key '%s'", identifier.Literal)
+			if v.Lookup(identifier.Literal) != nil {
+				return v, self.duplicateKeyError(identifier)
 			}
 
-			v[identifier.Literal] = sub
-		} else if next.TokenType == token.INT ||
-			next.TokenType == token.FLOAT ||
-			next.TokenType == token.BOOL ||
-			next.TokenType == token.STRING {
+			entry.Value, err = self.parseValue()
+			if err != nil {
+				return v, err
+			}
+			err = self.expectOneOf(token.NEW_LINE, token.EOF)
+			if err != nil {
+				return v, err
+			}
+		} else {
+			c := self.current()
+			return nil, self.syntaxError(c, "parse error: %s, %s", c.TokenType, c.Literal)
+		}
+		v.Entries = append(v.Entries, entry)
+		self.pop()
+	}
+}
 
-			// Make sure we're not overwriting an existing key
-			if _, ok := v[identifier.Literal]; ok {
-				return v, fmt.Errorf("duplicate key '%s'", identifier.Literal)
+// parseList parses a block of list items, one '- value' per line, at the
+// given depth
+func (self *parser) parseList(depth int) (*ast.ListNode, error) {
+	var v *ast.ListNode
+	var err error
+	var dash, next token.Token
+
+	v = &ast.ListNode{}
+
+	// Each iteration in the loop is expected to parse one list item
+	for {
+		self.release()
+
+		err = self.skipEmptyLines(true)
+		if err != nil {
+			return v, err
+		}
+
+		// End condition
+		if self.current().TokenType == token.EOF {
+			return v, nil
+		}
+
+		// Same rules for tabs as for maps
+		t := self.count(token.TAB)
+
+		if t > depth {
+			return v, self.indentationError(depth, t)
+		}
+
+		if t < depth {
+			if len(v.Items) == 0 {
+				return v, self.syntaxError(self.current(), "incomplete nested structure")
+			}
+
+			// we're 'moving up'
+			return v, nil
+		}
+
+		if self.consumeN(token.TAB, depth) == false {
+			return v, self.indentationError(depth, self.count(token.TAB))
+		}
+
+		// New line starts with a dash
+		err = self.expect(token.DASH)
+		if err != nil {
+			return v, err
+		}
+
+		dash = self.current()
+		item := &ast.ListItem{
+			Doc:  self.docFor(dash),
+			Dash: dash,
+		}
+
+		// After the dash there is either a value or a new line (nested
+		// object or list).
+		self.push(strconv.Itoa(len(v.Items)))
+		next = self.next()
+		if next.TokenType == token.NEW_LINE {
+			sub, err := self.parseNested(depth + 1)
+			if err != nil {
+				return v, err
 			}
 
-			v[identifier.Literal], err = self.tokenToValue(self.current())
+			item.Value = sub
+		} else if isValue(next) {
+			item.Value, err = self.parseValue()
 			if err != nil {
 				return v, err
 			}
-			self.next()
-			err = self.expect(token.NEW_LINE)
+
+			err = self.expectOneOf(token.NEW_LINE, token.EOF)
 			if err != nil {
 				return v, err
 			}
 		} else {
 			c := self.current()
-			return nil, fmt.Errorf("parse error: %s, %s", c.TokenType, c.Literal)
+			return nil, self.syntaxError(c, "parse error: %s, %s", c.TokenType, c.Literal)
+		}
+		v.Items = append(v.Items, item)
+		self.pop()
+	}
+}
+
+// parseNested parses the nested structure that follows a 'key:' or a '-' on
+// an otherwise empty line. Whether it's a map or a list is decided by the
+// first line of the structure.
+func (self *parser) parseNested(depth int) (ast.Node, error) {
+	var isList bool
+
+	// Look ahead without consuming anything
+	position := self.position
+	err := self.skipEmptyLines(false)
+	if err != nil {
+		return nil, err
+	}
+	if self.count(token.TAB) == depth {
+		self.consumeN(token.TAB, depth)
+		isList = self.current().TokenType == token.DASH
+	}
+	self.position = position
+
+	if isList {
+		return self.parseList(depth)
+	}
+
+	sub, err := self.parse(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sub.Entries) == 0 {
+		return nil, self.syntaxError(self.current(), "unfinished nested structure")
+	}
+
+	return sub, nil
+}
+
+// parseValue parses the scalar or flow collection starting at the current
+// token, and moves past it
+func (self *parser) parseValue() (ast.Node, error) {
+	switch self.current().TokenType {
+	case token.LEFT_BRACKET:
+		return self.parseFlowList()
+	case token.LEFT_BRACE:
+		return self.parseFlowMap()
+	}
+
+	tok := self.current()
+	v, err := self.tokenToValue(tok)
+	if err != nil {
+		return nil, err
+	}
+
+	self.next()
+	return &ast.ScalarNode{Token: tok, Value: v}, nil
+}
+
+// parseFlowList parses a list written on one line, e.g. '[1, 2, 3]'
+func (self *parser) parseFlowList() (*ast.ListNode, error) {
+	var v *ast.ListNode
+
+	v = &ast.ListNode{Open: self.current()}
+
+	err := self.expect(token.LEFT_BRACKET)
+	if err != nil {
+		return nil, err
+	}
+
+	if self.next().TokenType == token.RIGHT_BRACKET {
+		v.Close = self.current()
+		self.next()
+		return v, nil
+	}
+
+	for {
+		self.push(strconv.Itoa(len(v.Items)))
+		if isValue(self.current()) == false {
+			c := self.current()
+			return nil, self.syntaxError(c, "parse error: %s, %s", c.TokenType, c.Literal)
+		}
+		value, err := self.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		v.Items = append(v.Items, &ast.ListItem{Value: value})
+		self.pop()
+
+		switch self.current().TokenType {
+		case token.COMMA:
+			self.next()
+		case token.RIGHT_BRACKET:
+			v.Close = self.current()
+			self.next()
+			return v, nil
+		default:
+			return nil, self.expectOneOf(token.COMMA, token.RIGHT_BRACKET)
+		}
+	}
+}
+
+// parseFlowMap parses a map written on one line, e.g. '{a: 1, b: 2}'
+func (self *parser) parseFlowMap() (*ast.MapNode, error) {
+	var v *ast.MapNode
+	var identifier, colon token.Token
+
+	v = &ast.MapNode{Open: self.current()}
+
+	err := self.expect(token.LEFT_BRACE)
+	if err != nil {
+		return nil, err
+	}
+
+	if self.next().TokenType == token.RIGHT_BRACE {
+		v.Close = self.current()
+		self.next()
+		return v, nil
+	}
+
+	for {
+		err = self.expect(token.IDENTIFIER)
+		if err != nil {
+			return nil, err
+		}
+
+		identifier = self.current()
+		colon = self.next()
+
+		err = self.expect(token.COLON_SIGN)
+		if err != nil {
+			return nil, err
+		}
+
+		self.push(identifier.Literal)
+		if isValue(self.next()) == false {
+			c := self.current()
+			return nil, self.syntaxError(c, "parse error: %s, %s", c.TokenType, c.Literal)
 		}
+
+		// Make sure we're not overwriting an existing key
+		if v.Lookup(identifier.Literal) != nil {
+			return nil, self.duplicateKeyError(identifier)
+		}
+
+		value, err := self.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		v.Entries = append(v.Entries, &ast.KeyValue{Key: identifier, Colon: colon, Value: value})
+		self.pop()
+
+		switch self.current().TokenType {
+		case token.COMMA:
+			self.next()
+		case token.RIGHT_BRACE:
+			v.Close = self.current()
+			self.next()
+			return v, nil
+		default:
+			return nil, self.expectOneOf(token.COMMA, token.RIGHT_BRACE)
+		}
+	}
+}
+
+// skipEmptyLines consumes all new lines and comments (if there are any). The
+// comments are collected unless the parser is only looking ahead.
+func (self *parser) skipEmptyLines(collect bool) error {
+	for {
+		if self.current().TokenType == token.NEW_LINE {
+			self.next()
+			continue
+		}
+
+		// Comments may be indented
+		if self.current().TokenType == token.TAB {
+			n := self.count(token.TAB)
+			if self.fill(self.position+n) && self.tokens[self.position+n].TokenType == token.COMMENT {
+				self.consumeN(token.TAB, n)
+				continue
+			}
+		}
+
+		if self.current().TokenType == token.COMMENT {
+			if collect {
+				self.collect(self.current())
+			}
+
+			self.next()
+			err := self.expectOneOf(token.NEW_LINE, token.EOF)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		return nil
 	}
+}
+
+// collect adds the comment to the comment group on the line above it, or
+// starts a new group
+func (self *parser) collect(tok token.Token) {
+	comment := &ast.Comment{Token: tok}
+
+	if self.doc != nil && self.doc.List[len(self.doc.List)-1].Token.Line == tok.Line-1 {
+		self.doc.List = append(self.doc.List, comment)
+		return
+	}
+
+	self.doc = &ast.CommentGroup{List: []*ast.Comment{comment}}
+	self.comments = append(self.comments, self.doc)
+}
+
+// docFor returns the comment group that ends on the line above the token,
+// if there is one. Each group is only handed out once.
+func (self *parser) docFor(tok token.Token) *ast.CommentGroup {
+	doc := self.doc
+	self.doc = nil
+
+	if doc == nil || doc.List[len(doc.List)-1].Token.Line != tok.Line-1 {
+		return nil
+	}
+
+	return doc
+}
+
+// push appends a key or list index to the path of the current value
+func (self *parser) push(elem string) {
+	self.path = append(self.path, elem)
+}
+
+// pop removes the last element of the path of the current value
+func (self *parser) pop() {
+	self.path = self.path[:len(self.path)-1]
+}
 
-	return v, nil
+// currentPath returns a copy of the path of the current value
+func (self *parser) currentPath() []string {
+	return append([]string{}, self.path...)
+}
+
+// syntaxError returns a *SyntaxError pointing at the token
+func (self *parser) syntaxError(tok token.Token, format string, args ...interface{}) error {
+	return &SyntaxError{
+		Position: tok.Position,
+		Path:     self.currentPath(),
+		Token:    tok,
+		Msg:      fmt.Sprintf(format, args...),
+	}
+}
+
+// timeError returns a *SyntaxError for a date or time that is out of range,
+// e.g. 'invalid date 2024-13-01: month out of range'
+func (self *parser) timeError(tok token.Token, what string, err error) error {
+	msg := err.Error()
+	var parseErr *time.ParseError
+	if errors.As(err, &parseErr) && parseErr.Message != "" {
+		msg = strings.TrimPrefix(parseErr.Message, ": ")
+	}
+
+	return self.syntaxError(tok, "invalid %s %s: %s", what, tok.Literal, msg)
+}
+
+// duplicateKeyError returns a *DuplicateKeyError for the key, which is
+// expected to be the last element of the path
+func (self *parser) duplicateKeyError(key token.Token) error {
+	return &DuplicateKeyError{
+		Position: key.Position,
+		Path:     self.currentPath(),
+		Token:    key,
+		Key:      key.Literal,
+	}
+}
+
+// indentationError returns an *IndentationError for the line starting at the
+// current token
+func (self *parser) indentationError(expected, got int) error {
+	return &IndentationError{
+		Position: self.current().Position,
+		Path:     self.currentPath(),
+		Token:    self.current(),
+		Expected: expected,
+		Got:      got,
+	}
 }
 
 // expect ...
 func (self *parser) expect(tokenType token.TokenType) error {
 	t := self.current().TokenType
 	if t != tokenType {
-		return fmt.Errorf("expected %v, got %v", tokenType, t)
+		return self.syntaxError(self.current(), "expected %v, got %v", tokenType, t)
 	}
 	return nil
 }
@@ -165,7 +573,7 @@ func (self *parser) expectOneOf(tokenTypes ...token.TokenType) error {
 		}
 	}
 
-	return fmt.Errorf("expected on of %v", tokenTypes)
+	return self.syntaxError(self.current(), "expected one of %v, got %v", tokenTypes, tokenType)
 }
 
 // expectN ...
@@ -210,6 +618,7 @@ func (self *parser) prev() token.Token {
 }
 
 func (self *parser) current() token.Token {
+	self.fill(self.position)
 	return self.tokens[self.position]
 }
 
@@ -217,28 +626,117 @@ func (self *parser) current() token.Token {
 func (self *parser) peek() (token.Token, error) {
 	var tok token.Token
 	next := self.position + 1
-	if next >= len(self.tokens) {
+	if self.fill(next) == false {
 		return tok, fmt.Errorf("unexpected end of file")
 	}
 
 	return self.tokens[next], nil
 }
 
+// fill pulls tokens from the source until there is one at position i, and
+// reports whether there is
+func (self *parser) fill(i int) bool {
+	for i >= len(self.tokens) && self.source != nil {
+		tok, err := self.source.Next()
+		if err != nil && self.err == nil {
+			self.err = err
+			self.errPath = self.currentPath()
+		}
+
+		self.tokens = append(self.tokens, tok)
+	}
+
+	return i < len(self.tokens)
+}
+
+// release lets go of the tokens before the current one when they are pulled
+// from a source. It must not be called while a position is saved for
+// looking ahead.
+func (self *parser) release() {
+	if self.source == nil || self.position == 0 {
+		return
+	}
+
+	n := copy(self.tokens, self.tokens[self.position:])
+	self.tokens = self.tokens[:n]
+	self.position = 0
+}
+
+// isValue reports whether the token starts a value, either a scalar or a
+// flow collection
+func isValue(tok token.Token) bool {
+	switch tok.TokenType {
+	case token.INT, token.FLOAT, token.BOOL, token.STRING, token.NULL:
+		return true
+	case token.DURATION, token.BYTE_SIZE, token.DATE, token.TIME, token.DATETIME:
+		return true
+	case token.LEFT_BRACKET, token.LEFT_BRACE:
+		return true
+	}
+	return false
+}
+
 // tokenToValue ...
 func (self *parser) tokenToValue(tok token.Token) (interface{}, error) {
 	switch tok.TokenType {
 	case token.INT:
-		i, err := strconv.Atoi(tok.Literal)
+		// Integers that don't fit in an int64 but in a uint64, like bitmasks,
+		// become a uint64
+		i, err := strconv.ParseInt(tok.Literal, 0, 64)
+		if errors.Is(err, strconv.ErrRange) && strings.HasPrefix(tok.Literal, "-") == false {
+			u, err := strconv.ParseUint(strings.TrimPrefix(tok.Literal, "+"), 0, 64)
+			if err != nil {
+				return nil, self.syntaxError(tok, "integer %s overflows uint64", tok.Literal)
+			}
+			return u, nil
+		}
+		if errors.Is(err, strconv.ErrRange) {
+			return nil, self.syntaxError(tok, "integer %s overflows int64", tok.Literal)
+		}
 		if err != nil {
-			return nil, err
+			return nil, self.syntaxError(tok, "%v", err)
 		}
-		return i, nil
+		return int(i), nil
 	case token.FLOAT:
 		f, err := strconv.ParseFloat(tok.Literal, 64)
-		if err != nil {
-			return nil, err
+		if errors.Is(err, strconv.ErrRange) && math.IsInf(f, 0) {
+			return nil, self.syntaxError(tok, "float %s overflows float64", tok.Literal)
+		}
+		if err != nil && errors.Is(err, strconv.ErrRange) == false {
+			return nil, self.syntaxError(tok, "%v", err)
 		}
 		return f, nil
+	case token.DURATION:
+		d, err := time.ParseDuration(strings.ReplaceAll(tok.Literal, "_", ""))
+		if err != nil {
+			return nil, self.syntaxError(tok, "duration %s overflows int64", tok.Literal)
+		}
+		return d, nil
+	case token.BYTE_SIZE:
+		b, err := ast.ParseByteSize(tok.Literal)
+		if err != nil {
+			return nil, self.syntaxError(tok, "%v", err)
+		}
+		return b, nil
+	case token.DATE:
+		d, err := ast.ParseDate(tok.Literal)
+		if err != nil {
+			return nil, self.timeError(tok, "date", err)
+		}
+		return d, nil
+	case token.TIME:
+		// A time of day is a time.Time on January 1st, year 0, in UTC
+		t, err := time.Parse("15:04:05.999999999", tok.Literal)
+		if err != nil {
+			return nil, self.timeError(tok, "time", err)
+		}
+		return t, nil
+	case token.DATETIME:
+		t, err := time.Parse(time.RFC3339Nano, strings.ToUpper(tok.Literal))
+		if err != nil {
+			return nil, self.timeError(tok, "datetime", err)
+		}
+		return t, nil
 	case token.STRING:
 		return tok.Literal, nil
 	case token.BOOL:
@@ -248,8 +746,10 @@ func (self *parser) tokenToValue(tok token.Token) (interface{}, error) {
 		if tok.Literal == "false" {
 			return false, nil
 		}
-		return nil, fmt.Errorf("could not convert '%v' to bool value", tok.Literal)
+		return nil, self.syntaxError(tok, "could not convert '%v' to bool value", tok.Literal)
+	case token.NULL:
+		return nil, nil
 	default:
-		return nil, fmt.Errorf("unexpected token type %v", tok.TokenType)
+		return nil, self.syntaxError(tok, "unexpected token type %v", tok.TokenType)
 	}
 }
@@ -3,6 +3,7 @@ package parser
 import (
 	"testing"
 
+	"github.com/doctordesh/yrm/ast"
 	"github.com/doctordesh/yrm/token"
 	check "gitlab.com/MaxIV/lib-maxiv-go-check"
 )
@@ -154,3 +155,313 @@ func TestParseSingleValue(t *testing.T) {
 		}
 	}
 }
+
+func TestParseList(t *testing.T) {
+	type row struct {
+		tokens []token.Token
+		values map[string]interface{}
+		error  bool
+	}
+
+	table := []row{
+		// List of scalars
+		row{
+			tokens: []token.Token{
+				token.Token{TokenType: token.IDENTIFIER, Literal: "foo"},
+				token.Token{TokenType: token.COLON_SIGN, Literal: ":"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.TAB},
+				token.Token{TokenType: token.DASH, Literal: "-"},
+				token.Token{TokenType: token.INT, Literal: "42"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.TAB},
+				token.Token{TokenType: token.DASH, Literal: "-"},
+				token.Token{TokenType: token.STRING, Literal: "lorem"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.IDENTIFIER, Literal: "bar"},
+				token.Token{TokenType: token.COLON_SIGN, Literal: ":"},
+				token.Token{TokenType: token.INT, Literal: "84"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.EOF},
+			},
+			values: map[string]interface{}{
+				"foo": []interface{}{42, "lorem"},
+				"bar": 84,
+			},
+		},
+		// List of maps
+		row{
+			tokens: []token.Token{
+				token.Token{TokenType: token.IDENTIFIER, Literal: "foo"},
+				token.Token{TokenType: token.COLON_SIGN, Literal: ":"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.TAB},
+				token.Token{TokenType: token.DASH, Literal: "-"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.TAB},
+				token.Token{TokenType: token.TAB},
+				token.Token{TokenType: token.IDENTIFIER, Literal: "bar"},
+				token.Token{TokenType: token.COLON_SIGN, Literal: ":"},
+				token.Token{TokenType: token.INT, Literal: "1"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.TAB},
+				token.Token{TokenType: token.DASH, Literal: "-"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.TAB},
+				token.Token{TokenType: token.TAB},
+				token.Token{TokenType: token.IDENTIFIER, Literal: "bar"},
+				token.Token{TokenType: token.COLON_SIGN, Literal: ":"},
+				token.Token{TokenType: token.INT, Literal: "2"},
+				token.Token{TokenType: token.EOF},
+			},
+			values: map[string]interface{}{
+				"foo": []interface{}{
+					map[string]interface{}{"bar": 1},
+					map[string]interface{}{"bar": 2},
+				},
+			},
+		},
+		// List of lists
+		row{
+			tokens: []token.Token{
+				token.Token{TokenType: token.IDENTIFIER, Literal: "foo"},
+				token.Token{TokenType: token.COLON_SIGN, Literal: ":"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.TAB},
+				token.Token{TokenType: token.DASH, Literal: "-"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.TAB},
+				token.Token{TokenType: token.TAB},
+				token.Token{TokenType: token.DASH, Literal: "-"},
+				token.Token{TokenType: token.BOOL, Literal: "true"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.TAB},
+				token.Token{TokenType: token.TAB},
+				token.Token{TokenType: token.DASH, Literal: "-"},
+				token.Token{TokenType: token.BOOL, Literal: "false"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.TAB},
+				token.Token{TokenType: token.DASH, Literal: "-"},
+				token.Token{TokenType: token.FLOAT, Literal: "1.5"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.EOF},
+			},
+			values: map[string]interface{}{
+				"foo": []interface{}{
+					[]interface{}{true, false},
+					1.5,
+				},
+			},
+		},
+		// Too many tabs on a list item
+		row{
+			tokens: []token.Token{
+				token.Token{TokenType: token.IDENTIFIER, Literal: "foo"},
+				token.Token{TokenType: token.COLON_SIGN, Literal: ":"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.TAB},
+				token.Token{TokenType: token.DASH, Literal: "-"},
+				token.Token{TokenType: token.INT, Literal: "1"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.TAB},
+				token.Token{TokenType: token.TAB},
+				token.Token{TokenType: token.DASH, Literal: "-"},
+				token.Token{TokenType: token.INT, Literal: "2"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.EOF},
+			},
+			error: true,
+		},
+		// Mixing list items and keys is not allowed
+		row{
+			tokens: []token.Token{
+				token.Token{TokenType: token.IDENTIFIER, Literal: "foo"},
+				token.Token{TokenType: token.COLON_SIGN, Literal: ":"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.TAB},
+				token.Token{TokenType: token.DASH, Literal: "-"},
+				token.Token{TokenType: token.INT, Literal: "1"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.TAB},
+				token.Token{TokenType: token.IDENTIFIER, Literal: "bar"},
+				token.Token{TokenType: token.COLON_SIGN, Literal: ":"},
+				token.Token{TokenType: token.INT, Literal: "2"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.EOF},
+			},
+			error: true,
+		},
+		// Empty list item
+		row{
+			tokens: []token.Token{
+				token.Token{TokenType: token.IDENTIFIER, Literal: "foo"},
+				token.Token{TokenType: token.COLON_SIGN, Literal: ":"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.TAB},
+				token.Token{TokenType: token.DASH, Literal: "-"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.EOF},
+			},
+			error: true,
+		},
+	}
+
+	for i, r := range table {
+		m := New(r.tokens)
+		res, err := m.Parse()
+		if r.error {
+			check.NotOKWithMessage(t, err, "row: %d", i+1)
+		} else {
+			check.EqualsWithMessage(t, r.values, res, "row: %d", i+1)
+			check.OKWithMessage(t, err, "row: %d", i+1)
+		}
+	}
+}
+
+func TestParseFlow(t *testing.T) {
+	type row struct {
+		tokens []token.Token
+		values map[string]interface{}
+		error  bool
+	}
+
+	table := []row{
+		// [1, {a: true}, []]
+		row{
+			tokens: []token.Token{
+				token.Token{TokenType: token.IDENTIFIER, Literal: "foo"},
+				token.Token{TokenType: token.COLON_SIGN, Literal: ":"},
+				token.Token{TokenType: token.LEFT_BRACKET, Literal: "["},
+				token.Token{TokenType: token.INT, Literal: "1"},
+				token.Token{TokenType: token.COMMA, Literal: ","},
+				token.Token{TokenType: token.LEFT_BRACE, Literal: "{"},
+				token.Token{TokenType: token.IDENTIFIER, Literal: "a"},
+				token.Token{TokenType: token.COLON_SIGN, Literal: ":"},
+				token.Token{TokenType: token.BOOL, Literal: "true"},
+				token.Token{TokenType: token.RIGHT_BRACE, Literal: "}"},
+				token.Token{TokenType: token.COMMA, Literal: ","},
+				token.Token{TokenType: token.LEFT_BRACKET, Literal: "["},
+				token.Token{TokenType: token.RIGHT_BRACKET, Literal: "]"},
+				token.Token{TokenType: token.RIGHT_BRACKET, Literal: "]"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.EOF},
+			},
+			values: map[string]interface{}{
+				"foo": []interface{}{
+					1,
+					map[string]interface{}{"a": true},
+					[]interface{}{},
+				},
+			},
+		},
+		// duplicate key in flow map
+		row{
+			tokens: []token.Token{
+				token.Token{TokenType: token.IDENTIFIER, Literal: "foo"},
+				token.Token{TokenType: token.COLON_SIGN, Literal: ":"},
+				token.Token{TokenType: token.LEFT_BRACE, Literal: "{"},
+				token.Token{TokenType: token.IDENTIFIER, Literal: "a"},
+				token.Token{TokenType: token.COLON_SIGN, Literal: ":"},
+				token.Token{TokenType: token.INT, Literal: "1"},
+				token.Token{TokenType: token.COMMA, Literal: ","},
+				token.Token{TokenType: token.IDENTIFIER, Literal: "a"},
+				token.Token{TokenType: token.COLON_SIGN, Literal: ":"},
+				token.Token{TokenType: token.INT, Literal: "2"},
+				token.Token{TokenType: token.RIGHT_BRACE, Literal: "}"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.EOF},
+			},
+			error: true,
+		},
+		// trailing comma
+		row{
+			tokens: []token.Token{
+				token.Token{TokenType: token.IDENTIFIER, Literal: "foo"},
+				token.Token{TokenType: token.COLON_SIGN, Literal: ":"},
+				token.Token{TokenType: token.LEFT_BRACKET, Literal: "["},
+				token.Token{TokenType: token.INT, Literal: "1"},
+				token.Token{TokenType: token.COMMA, Literal: ","},
+				token.Token{TokenType: token.RIGHT_BRACKET, Literal: "]"},
+				token.Token{TokenType: token.NEW_LINE},
+				token.Token{TokenType: token.EOF},
+			},
+			error: true,
+		},
+	}
+
+	for i, r := range table {
+		m := New(r.tokens)
+		res, err := m.Parse()
+		if r.error {
+			check.NotOKWithMessage(t, err, "row: %d", i+1)
+		} else {
+			check.EqualsWithMessage(t, r.values, res, "row: %d", i+1)
+			check.OKWithMessage(t, err, "row: %d", i+1)
+		}
+	}
+}
+
+// sliceSource hands out tokens from a slice, one at a time
+type sliceSource struct {
+	tokens []token.Token
+	index  int
+}
+
+func (self *sliceSource) Next() (token.Token, error) {
+	tok := self.tokens[self.index]
+	if self.index < len(self.tokens)-1 {
+		self.index += 1
+	}
+	return tok, nil
+}
+
+func TestParseStream(t *testing.T) {
+	line := []token.Token{
+		token.Token{TokenType: token.TAB},
+		token.Token{TokenType: token.DASH, Literal: "-"},
+		token.Token{TokenType: token.INT, Literal: "1"},
+		token.Token{TokenType: token.NEW_LINE},
+	}
+
+	tokens := []token.Token{
+		token.Token{TokenType: token.IDENTIFIER, Literal: "foo"},
+		token.Token{TokenType: token.COLON_SIGN, Literal: ":"},
+		token.Token{TokenType: token.NEW_LINE},
+	}
+	for i := 0; i < 100; i++ {
+		tokens = append(tokens, line...)
+	}
+	tokens = append(tokens, token.Token{TokenType: token.EOF})
+
+	p := NewStream(&sliceSource{tokens: tokens})
+	res, err := p.Parse()
+	check.OK(t, err)
+	check.Equals(t, 100, len(res["foo"].([]interface{})))
+
+	// tokens of parsed lines have been let go of
+	check.Assert(t, len(p.tokens) < len(line)+1)
+}
+
+func TestParseIndentedComment(t *testing.T) {
+	tokens := []token.Token{
+		token.Token{TokenType: token.IDENTIFIER, Literal: "foo"},
+		token.Token{TokenType: token.COLON_SIGN, Literal: ":"},
+		token.Token{TokenType: token.NEW_LINE},
+		token.Token{TokenType: token.TAB},
+		token.Token{TokenType: token.TAB},
+		token.Token{TokenType: token.COMMENT, Literal: "// a comment"},
+		token.Token{TokenType: token.NEW_LINE},
+		token.Token{TokenType: token.TAB},
+		token.Token{TokenType: token.IDENTIFIER, Literal: "bar"},
+		token.Token{TokenType: token.COLON_SIGN, Literal: ":"},
+		token.Token{TokenType: token.INT, Literal: "42"},
+		token.Token{TokenType: token.NEW_LINE},
+		token.Token{TokenType: token.EOF},
+	}
+
+	file, err := New(tokens).ParseAST()
+	check.OK(t, err)
+	check.Equals(t, 1, len(file.Comments))
+	check.Equals(t, "a comment", file.Comments[0].Text())
+	check.Equals(t, "bar", file.Map.Lookup("foo").Value.(*ast.MapNode).Entries[0].Key.Literal)
+}
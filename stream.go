@@ -0,0 +1,61 @@
+package yrm
+
+import (
+	"io"
+	"reflect"
+)
+
+// A Decoder reads and decodes a YRM document from an input stream
+type Decoder struct {
+	r    io.Reader
+	done bool
+}
+
+// NewDecoder returns a new decoder that reads from r. The input is read as
+// it's parsed, it's never held in memory as a whole.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Decode reads the document from its input and stores it in the value
+// pointed to by v, see Unmarshal. A document runs until the end of the
+// input, so every call after the first returns io.EOF.
+func (self *Decoder) Decode(v interface{}) error {
+	if self.done {
+		return io.EOF
+	}
+	self.done = true
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &InvalidUnmarshalError{Type: reflect.TypeOf(v)}
+	}
+
+	m, err := parse("", self.r)
+	if err != nil {
+		return err
+	}
+
+	return unmarshal(nil, m, rv)
+}
+
+// An Encoder writes YRM documents to an output stream
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns a new encoder that writes to w
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the YRM encoding of v to the stream, see Marshal
+func (self *Encoder) Encode(v interface{}) error {
+	b, err := Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = self.w.Write(b)
+	return err
+}
@@ -0,0 +1,73 @@
+package yrm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	check "gitlab.com/MaxIV/lib-maxiv-go-check"
+)
+
+func TestDecoder(t *testing.T) {
+	var c testConfig
+
+	dec := NewDecoder(iotest.OneByteReader(strings.NewReader(decodeInput)))
+	err := dec.Decode(&c)
+	check.OK(t, err)
+	check.Equals(t, "localhost", c.Host)
+	check.Equals(t, 8888, c.Ports.HTTP)
+
+	err = dec.Decode(&c)
+	check.Equals(t, io.EOF, err)
+}
+
+func TestDecoderPipe(t *testing.T) {
+	r, w := io.Pipe()
+
+	// A generated document that's never held in memory as a whole
+	n := 10000
+	go func() {
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(w, "key_%s:\n\tvalue: %d\n\tlist: [1, 2]\n", strings.Repeat("a", i%7+1), i)
+		}
+		w.Close()
+	}()
+
+	var m map[string]interface{}
+	err := NewDecoder(r).Decode(&m)
+	check.NotOK(t, err) // the keys repeat
+
+	var dup *DuplicateKeyError
+	check.AssertWithMessage(t, errors.As(err, &dup), "expected *DuplicateKeyError, got %v", err)
+	check.Equals(t, 22, dup.Position.Line)
+}
+
+func TestDecoderErrors(t *testing.T) {
+	var m map[string]interface{}
+
+	readErr := errors.New("connection reset")
+	err := NewDecoder(iotest.ErrReader(readErr)).Decode(&m)
+	check.AssertWithMessage(t, errors.Is(err, readErr), "expected read error, got %v", err)
+
+	err = NewDecoder(strings.NewReader("a: 1\n")).Decode(m)
+	check.Equals(t, "yrm: Unmarshal(non-pointer map[string]interface {})", err.Error())
+
+	var syntax *SyntaxError
+	err = NewDecoder(strings.NewReader("a: [1\n")).Decode(&m)
+	check.AssertWithMessage(t, errors.As(err, &syntax), "expected *SyntaxError, got %v", err)
+}
+
+func TestEncoder(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := NewEncoder(&buf).Encode(map[string]interface{}{"a": []int{1, 2}})
+	check.OK(t, err)
+	check.Equals(t, "a:\n\t- 1\n\t- 2\n", buf.String())
+
+	err = NewEncoder(&buf).Encode(5)
+	check.NotOK(t, err)
+}
@@ -15,17 +15,63 @@ const (
 	FLOAT  TokenType = "FLOAT"
 	STRING TokenType = "STRING"
 	BOOL   TokenType = "BOOL"
+	NULL   TokenType = "NULL"
+
+	DURATION  TokenType = "DURATION"  // e.g. 1h30m
+	BYTE_SIZE TokenType = "BYTE_SIZE" // e.g. 512KiB
+	DATE      TokenType = "DATE"      // e.g. 2024-01-15
+	TIME      TokenType = "TIME"      // e.g. 10:30:00
+	DATETIME  TokenType = "DATETIME"  // e.g. 2024-01-15T10:30:00Z
 
 	// Special characters
 	COLON_SIGN TokenType = "COLON_SIGN"
 	NEW_LINE   TokenType = "NEW_LINE"
 	TAB        TokenType = "TAB"
+	DASH       TokenType = "DASH"
 	COMMENT    TokenType = "COMMENT"
+
+	// Flow collections
+	LEFT_BRACKET  TokenType = "LEFT_BRACKET"
+	RIGHT_BRACKET TokenType = "RIGHT_BRACKET"
+	LEFT_BRACE    TokenType = "LEFT_BRACE"
+	RIGHT_BRACE   TokenType = "RIGHT_BRACE"
+	COMMA         TokenType = "COMMA"
 )
 
+// Position is a location in the input, pointing at the first byte of a token
+type Position struct {
+	Filename string // may be empty
+	Offset   int    // byte offset, starting at 0
+	Line     int    // line number, starting at 1
+	Column   int    // column number (in bytes), starting at 1
+}
+
+// IsValid reports whether the position has been set
+func (self Position) IsValid() bool {
+	return self.Line > 0
+}
+
+// String returns the position as 'file:line:col', 'line:col' if there is no
+// filename, or '-' if the position is not valid
+func (self Position) String() string {
+	s := self.Filename
+	if self.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", self.Line, self.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
+
 type Token struct {
 	TokenType TokenType
 	Literal   string
+	Position      // where the token starts, including any quotes
+	End       int // offset of the first byte after the token
 }
 
 // String transforms the token into a representable string
@@ -41,6 +87,10 @@ func (self Token) String() string {
 		return string(self.TokenType)
 	case TAB:
 		return string(self.TokenType)
+	case DASH:
+		return string(self.TokenType)
+	case LEFT_BRACKET, RIGHT_BRACKET, LEFT_BRACE, RIGHT_BRACE, COMMA:
+		return string(self.TokenType)
 	}
 
 	return fmt.Sprintf("%s with value '%s'", self.TokenType, self.Literal)
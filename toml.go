@@ -0,0 +1,224 @@
+package yrm
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ToTOML converts the YRM input to TOML. Maps become tables and lists of
+// maps become arrays of tables, other lists are written inline. Keys are
+// sorted, with the values of a table before its sub-tables.
+func ToTOML(src []byte) ([]byte, error) {
+	m, err := parse("", bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	w := &tomlWriter{}
+	err = w.table(nil, nil, m)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.buf.Bytes(), nil
+}
+
+type tomlWriter struct {
+	buf bytes.Buffer
+}
+
+// table writes the keys and values of the table, and then its sub-tables.
+// The header is the dotted key of the table, the path is the same with list
+// indexes, for errors.
+func (self *tomlWriter) table(header, path []string, m map[string]interface{}) error {
+	keys := sortedKeys(m)
+
+	for _, key := range keys {
+		if isTOMLTable(m[key]) || isTOMLTableArray(m[key]) {
+			continue
+		}
+
+		s, err := tomlValue(appendPath(path, key), m[key])
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(&self.buf, "%s = %s\n", tomlKey(key), s)
+	}
+
+	for _, key := range keys {
+		h := appendPath(header, key)
+		p := appendPath(path, key)
+
+		switch v := m[key].(type) {
+		case map[string]interface{}:
+			self.header("[" + tomlPath(h) + "]")
+
+			err := self.table(h, p, v)
+			if err != nil {
+				return err
+			}
+		case []interface{}:
+			if isTOMLTableArray(v) == false {
+				continue
+			}
+
+			for i, item := range v {
+				self.header("[[" + tomlPath(h) + "]]")
+
+				err := self.table(h, appendPath(p, strconv.Itoa(i)), item.(map[string]interface{}))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// header writes a table header, with a blank line above it
+func (self *tomlWriter) header(header string) {
+	if self.buf.Len() > 0 {
+		self.buf.WriteString("\n")
+	}
+	self.buf.WriteString(header)
+	self.buf.WriteString("\n")
+}
+
+// isTOMLTable reports whether the value is written as a table
+func isTOMLTable(v interface{}) bool {
+	_, ok := v.(map[string]interface{})
+	return ok
+}
+
+// isTOMLTableArray reports whether the value is a non-empty list of maps,
+// which is written as an array of tables
+func isTOMLTableArray(v interface{}) bool {
+	list, ok := v.([]interface{})
+	if ok == false || len(list) == 0 {
+		return false
+	}
+
+	for _, item := range list {
+		if isTOMLTable(item) == false {
+			return false
+		}
+	}
+	return true
+}
+
+// tomlValue returns the value as it is written after 'key = ', with maps as
+// inline tables
+func tomlValue(path []string, v interface{}) (string, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		var parts []string
+		for _, key := range sortedKeys(v) {
+			s, err := tomlValue(appendPath(path, key), v[key])
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, tomlKey(key)+" = "+s)
+		}
+		return "{" + strings.Join(parts, ", ") + "}", nil
+	case []interface{}:
+		var parts []string
+		for i, item := range v {
+			s, err := tomlValue(appendPath(path, strconv.Itoa(i)), item)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, s)
+		}
+		return "[" + strings.Join(parts, ", ") + "]", nil
+	case string:
+		return tomlString(v), nil
+	case time.Duration:
+		return tomlString(v.String()), nil
+	case ByteSize:
+		return strconv.FormatInt(int64(v), 10), nil
+	case nil:
+		return "", &ConvertError{Path: path, Msg: "TOML has no null"}
+	case uint64:
+		if v > math.MaxInt64 {
+			return "", &ConvertError{Path: path, Msg: fmt.Sprintf("%d is out of range for TOML", v)}
+		}
+	}
+
+	s, err := scalar(path, reflect.ValueOf(v))
+	if err != nil {
+		return "", &ConvertError{Path: path, Msg: fmt.Sprintf("%v has no TOML equivalent", v)}
+	}
+	return s, nil
+}
+
+// tomlKey returns the key bare, or quoted if it has characters a bare key
+// cannot have
+func tomlKey(key string) string {
+	if key == "" {
+		return `""`
+	}
+
+	for _, r := range key {
+		if ('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '_' || r == '-') == false {
+			return tomlString(key)
+		}
+	}
+	return key
+}
+
+// tomlPath returns the dotted key of a table header
+func tomlPath(path []string) string {
+	var keys []string
+	for _, key := range path {
+		keys = append(keys, tomlKey(key))
+	}
+	return strings.Join(keys, ".")
+}
+
+// tomlString returns the string as a TOML basic string
+func tomlString(s string) string {
+	var b strings.Builder
+
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+
+	return b.String()
+}
+
+// sortedKeys returns the keys of the map in order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,109 @@
+package yrm
+
+import (
+	"testing"
+
+	check "gitlab.com/MaxIV/lib-maxiv-go-check"
+)
+
+func TestToTOML(t *testing.T) {
+	input := `title: "say \"hi\""
+ports:
+	http: 80
+	ratio: 1.0
+	limits:
+		cpu: 2
+servers:
+	-
+		host: "a"
+		tags: [1, 2]
+	-
+		host: "b"
+matrix: [[1, 2], [{x: true}]]
+empty: {}
+none: []
+`
+
+	b, err := ToTOML([]byte(input))
+	check.OK(t, err)
+
+	exp := `matrix = [[1, 2], [{x = true}]]
+none = []
+title = "say \"hi\""
+
+[empty]
+
+[ports]
+http = 80
+ratio = 1.0
+
+[ports.limits]
+cpu = 2
+
+[[servers]]
+host = "a"
+tags = [1, 2]
+
+[[servers]]
+host = "b"
+`
+	check.Equals(t, exp, string(b))
+}
+
+func TestToTOMLNull(t *testing.T) {
+	_, err := ToTOML([]byte("a:\n\tb: null\n"))
+	check.NotOK(t, err)
+	check.Equals(t, "yrm: cannot convert 'a.b': TOML has no null", err.Error())
+}
+
+func TestTOMLString(t *testing.T) {
+	check.Equals(t, `"a\"b\\c\nd\u0001é"`, tomlString("a\"b\\c\nd\x01é"))
+	check.Equals(t, `"my key"`, tomlKey("my key"))
+	check.Equals(t, "my-key_1", tomlKey("my-key_1"))
+}
+
+func TestToTOMLTableArrayNested(t *testing.T) {
+	input := `servers:
+	-
+		host: "a"
+		db:
+			port: 1
+	-
+		host: "b"
+		subs:
+			- {name: "x"}
+		bad: null
+`
+
+	_, err := ToTOML([]byte(input))
+	check.NotOK(t, err)
+	check.Equals(t, "yrm: cannot convert 'servers.1.bad': TOML has no null", err.Error())
+
+	input = `servers:
+	-
+		host: "a"
+		db:
+			port: 1
+	-
+		host: "b"
+		subs:
+			- {name: "x"}
+`
+
+	b, err := ToTOML([]byte(input))
+	check.OK(t, err)
+
+	exp := `[[servers]]
+host = "a"
+
+[servers.db]
+port = 1
+
+[[servers]]
+host = "b"
+
+[[servers.subs]]
+name = "x"
+`
+	check.Equals(t, exp, string(b))
+}
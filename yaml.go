@@ -0,0 +1,352 @@
+package yrm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/doctordesh/yrm/ast"
+	"gopkg.in/yaml.v3"
+)
+
+// FromYAML converts the YAML input to YRM. The top level must be a mapping,
+// and keys are written in the order of the input. Comments are kept, with a
+// comment at the end of a line moved to the line above it.
+//
+// Everything in the input that YRM cannot express, such as anchors, aliases,
+// tags or more than one document, is returned together as
+// ConvertErrors, each with the line it is on.
+func FromYAML(src []byte) ([]byte, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(src))
+
+	var doc yaml.Node
+	err := dec.Decode(&doc)
+	if err == io.EOF {
+		return []byte{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("yrm: invalid YAML: %w", err)
+	}
+
+	c := &yamlConverter{}
+	m := c.document(&doc)
+
+	for {
+		var next yaml.Node
+		err = dec.Decode(&next)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("yrm: invalid YAML: %w", err)
+		}
+
+		c.fail(nil, &next, "more than one document")
+	}
+
+	if len(c.errs) > 0 {
+		return nil, c.errs
+	}
+
+	e := &encoder{}
+	err = e.encodeEntries(nil, m, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	e.comment(c.pending, 0)
+	return e.buf.Bytes(), nil
+}
+
+// yamlConverter turns YAML nodes into values for the encoder
+type yamlConverter struct {
+	errs    ConvertErrors
+	pending []string // comments to write above the next key or list item
+}
+
+func (self *yamlConverter) fail(path []string, node *yaml.Node, format string, args ...interface{}) {
+	self.errs = append(self.errs, &ConvertError{Path: path, Line: node.Line, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (self *yamlConverter) document(doc *yaml.Node) orderedMap {
+	self.pending = append(self.pending, yamlComment(doc.HeadComment)...)
+	defer func() {
+		self.pending = append(self.pending, yamlComment(doc.FootComment)...)
+	}()
+
+	if len(doc.Content) == 0 {
+		return orderedMap{}
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		self.fail(nil, root, "the top level must be a mapping")
+		return nil
+	}
+
+	return self.value(nil, root).(orderedMap)
+}
+
+func (self *yamlConverter) value(path []string, node *yaml.Node) interface{} {
+	if node.Anchor != "" {
+		self.fail(path, node, "anchor '&%s'", node.Anchor)
+	}
+
+	tag := node.ShortTag()
+	if strings.HasPrefix(tag, "!!") == false {
+		self.fail(path, node, "tag '%s'", tag)
+		return nil
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		return self.mapping(path, node)
+	case yaml.SequenceNode:
+		return self.sequence(path, node)
+	case yaml.AliasNode:
+		self.fail(path, node, "alias '*%s'", node.Value)
+		return nil
+	}
+
+	return self.scalar(path, node)
+}
+
+func (self *yamlConverter) mapping(path []string, node *yaml.Node) orderedMap {
+	m := orderedMap{}
+	seen := map[string]bool{}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+
+		comment := append(self.pending, yamlComment(key.HeadComment)...)
+		comment = append(comment, yamlComment(key.LineComment)...)
+		comment = append(comment, yamlComment(value.LineComment)...)
+		self.pending = nil
+
+		switch {
+		case key.Kind != yaml.ScalarNode || key.ShortTag() != "!!str":
+			self.fail(path, key, "key '%s' is not a string", key.Value)
+		case seen[key.Value]:
+			self.fail(path, key, "duplicate key '%s'", key.Value)
+		default:
+			seen[key.Value] = true
+			v := self.value(appendPath(path, key.Value), value)
+			m = append(m, entry{key: key.Value, value: reflect.ValueOf(v), comment: comment})
+		}
+
+		self.pending = append(self.pending, yamlComment(key.FootComment)...)
+		self.pending = append(self.pending, yamlComment(value.FootComment)...)
+	}
+
+	return m
+}
+
+func (self *yamlConverter) sequence(path []string, node *yaml.Node) []interface{} {
+	list := []interface{}{}
+
+	for i, item := range node.Content {
+		comment := append(self.pending, yamlComment(item.HeadComment)...)
+		comment = append(comment, yamlComment(item.LineComment)...)
+		self.pending = nil
+
+		v := self.value(appendPath(path, strconv.Itoa(i)), item)
+		if len(comment) > 0 {
+			list = append(list, commented{comment: comment, value: v})
+		} else {
+			list = append(list, v)
+		}
+
+		self.pending = append(self.pending, yamlComment(item.FootComment)...)
+	}
+
+	return list
+}
+
+func (self *yamlConverter) scalar(path []string, node *yaml.Node) interface{} {
+	switch tag := node.ShortTag(); tag {
+	case "!!str":
+		return node.Value
+	case "!!int":
+		var i int
+		if node.Decode(&i) == nil {
+			return i
+		}
+		var u uint64
+		if node.Decode(&u) == nil {
+			return u
+		}
+		self.fail(path, node, "number %s is out of range", node.Value)
+		return nil
+	case "!!float":
+		var f float64
+		err := node.Decode(&f)
+		if err != nil {
+			self.fail(path, node, "number %s is out of range", node.Value)
+		}
+		return f
+	case "!!bool":
+		var b bool
+		node.Decode(&b)
+		return b
+	case "!!timestamp":
+		if d, err := ast.ParseDate(node.Value); err == nil {
+			return d
+		}
+		var t time.Time
+		err := node.Decode(&t)
+		if err != nil {
+			self.fail(path, node, "timestamp %s is out of range", node.Value)
+		}
+		return t
+	case "!!null":
+		return nil
+	default:
+		self.fail(path, node, "%s values have no YRM equivalent", tag)
+	}
+
+	return nil
+}
+
+// yamlComment returns the lines of a YAML comment without the '#'
+func yamlComment(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "#")
+		lines = append(lines, strings.TrimPrefix(line, " "))
+	}
+	return lines
+}
+
+// ToYAML converts the YRM input to YAML, indented with two spaces. Comments
+// right above a key or list item are kept, other comments are dropped.
+func ToYAML(src []byte) ([]byte, error) {
+	file, err := parseAST("", bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := yamlNode(nil, file.Map)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+
+	err = enc.Encode(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}})
+	if err != nil {
+		return nil, err
+	}
+
+	err = enc.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// yamlNode returns the YAML node for a node of the syntax tree
+func yamlNode(path []string, node ast.Node) (*yaml.Node, error) {
+	switch n := node.(type) {
+	case *ast.MapNode:
+		res := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		if n.Flow() {
+			res.Style = yaml.FlowStyle
+		}
+
+		for _, e := range n.Entries {
+			key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: e.Key.Literal, HeadComment: yamlText(e.Doc)}
+
+			value, err := yamlNode(appendPath(path, e.Key.Literal), e.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			res.Content = append(res.Content, key, value)
+		}
+		return res, nil
+	case *ast.ListNode:
+		res := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		if n.Flow() {
+			res.Style = yaml.FlowStyle
+		}
+
+		for i, item := range n.Items {
+			value, err := yamlNode(appendPath(path, strconv.Itoa(i)), item.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			value.HeadComment = yamlText(item.Doc)
+			res.Content = append(res.Content, value)
+		}
+		return res, nil
+	case *ast.ScalarNode:
+		switch v := n.Value.(type) {
+		case string:
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}, nil
+		case bool:
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v)}, nil
+		case int:
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(v)}, nil
+		case uint64:
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatUint(v, 10)}, nil
+		case ByteSize:
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatInt(int64(v), 10)}, nil
+		case time.Duration:
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v.String()}, nil
+		case Date:
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!timestamp", Value: v.String()}, nil
+		case time.Time:
+			s := formatTime(v)
+			if strings.Contains(s, "T") == false {
+				// YAML has no time of day
+				return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}, nil
+			}
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!timestamp", Value: s}, nil
+		case nil:
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+		case float64:
+			s, err := scalar(path, reflect.ValueOf(v))
+			if err != nil {
+				return nil, &ConvertError{Path: path, Msg: fmt.Sprintf("%v has no YAML equivalent", v)}
+			}
+			// YAML spells infinity and NaN with a leading dot
+			switch s {
+			case "inf":
+				s = ".inf"
+			case "-inf":
+				s = "-.inf"
+			case "nan":
+				s = ".nan"
+			}
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: s}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("yrm: unknown node %T", node)
+}
+
+// yamlText returns the comments as YAML comment lines
+func yamlText(group *ast.CommentGroup) string {
+	if group == nil {
+		return ""
+	}
+
+	var lines []string
+	for _, line := range strings.Split(group.Text(), "\n") {
+		lines = append(lines, "# "+line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,122 @@
+package yrm
+
+import (
+	"errors"
+	"testing"
+
+	check "gitlab.com/MaxIV/lib-maxiv-go-check"
+)
+
+func TestFromYAML(t *testing.T) {
+	input := `# top
+
+# the host
+host: localhost
+port: 0x50 # hex
+ratio: 1.0
+# after ratio
+
+servers:
+  # first
+  - name: a
+    tags: [1, 2]
+  - b
+empty: {}
+unset: ~
+expires: 2025-06-30
+# end
+`
+
+	b, err := FromYAML([]byte(input))
+	check.OK(t, err)
+
+	exp := `// top
+// the host
+host: "localhost"
+// hex
+port: 80
+ratio: 1.0
+// after ratio
+servers:
+	// first
+	-
+		name: "a"
+		tags:
+			- 1
+			- 2
+	- "b"
+empty: {}
+unset: null
+expires: 2025-06-30
+// end
+`
+	check.Equals(t, exp, string(b))
+
+	_, err = Parse(string(b))
+	check.OK(t, err)
+}
+
+func TestFromYAMLErrors(t *testing.T) {
+	input := `base: &base
+  a: 1
+copy: *base
+tagged: !custom 5
+nothing: null
+"my key": 1
+---
+second: true
+`
+
+	_, err := FromYAML([]byte(input))
+	check.NotOK(t, err)
+
+	exp := `yrm: line 1: cannot convert 'base': anchor '&base'
+yrm: line 3: cannot convert 'copy': alias '*base'
+yrm: line 4: cannot convert 'tagged': tag '!custom'
+yrm: line 7: cannot convert: more than one document`
+	check.Equals(t, exp, err.Error())
+
+	var errs ConvertErrors
+	check.Assert(t, errors.As(err, &errs))
+	check.Equals(t, 4, len(errs))
+
+	_, err = FromYAML([]byte("- 1\n- 2\n"))
+	check.NotOK(t, err)
+}
+
+func TestToYAML(t *testing.T) {
+	input := `// the host
+host: "localhost"
+ports:
+	http: 80
+	ratio: 5.0
+servers:
+	// first
+	- {name: "a", tags: [1, 2]}
+`
+
+	b, err := ToYAML([]byte(input))
+	check.OK(t, err)
+
+	exp := `# the host
+host: localhost
+ports:
+  http: 80
+  ratio: 5.0
+servers:
+  # first
+  - {name: a, tags: [1, 2]}
+`
+	check.Equals(t, exp, string(b))
+
+	// and back again, with the flow collections as blocks
+	b, err = FromYAML(b)
+	check.OK(t, err)
+
+	v, err := Parse(string(b))
+	check.OK(t, err)
+
+	orig, err := Parse(input)
+	check.OK(t, err)
+	check.Equals(t, orig, v)
+}
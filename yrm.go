@@ -1,35 +1,99 @@
 package yrm
 
 import (
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"os"
+	"strings"
 
+	"github.com/doctordesh/yrm/ast"
 	"github.com/doctordesh/yrm/lexer"
 	"github.com/doctordesh/yrm/parser"
+	"github.com/doctordesh/yrm/token"
 )
 
+// ByteSize is a number of bytes, parsed from values like 512KiB or 2GB.
+// Durations like 1h30m are parsed as time.Duration.
+type ByteSize = ast.ByteSize
+
+// Date is a calendar date, parsed from values like 2024-01-15. Datetimes like
+// 2024-01-15T10:30:00Z, and times of day like 10:30:00, are parsed as
+// time.Time. A time of day is on January 1st of year 0, in UTC.
+type Date = ast.Date
+
+// ParseFile reads and parses the file, see Parse
 func ParseFile(filename string) (map[string]interface{}, error) {
-	input, err := ioutil.ReadFile(filename)
+	f, err := os.Open(filename)
 	if err != nil {
 		return nil, fmt.Errorf("could not parse file %s: %w", filename, err)
 	}
+	defer f.Close()
 
-	return Parse(string(input))
+	return parse(filename, f)
 }
 
+// Parse parses the input into a key-value map. Errors in the input are
+// returned as *SyntaxError, *DuplicateKeyError or *IndentationError
 func Parse(input string) (map[string]interface{}, error) {
-	l := lexer.New(input)
+	return parse("", strings.NewReader(input))
+}
+
+// ParseAST parses the input into a syntax tree, which unlike the map
+// returned by Parse keeps the order of keys, the comments and the position of
+// every node
+func ParseAST(input string) (*ast.File, error) {
+	return parseAST("", strings.NewReader(input))
+}
 
-	tokens, err := l.Lex()
+// parse parses the input as it is read, using filename (which may be empty)
+// in the position of errors
+func parse(filename string, r io.Reader) (map[string]interface{}, error) {
+	file, err := parseAST(filename, r)
 	if err != nil {
-		return nil, fmt.Errorf("could not lex: %w", err)
+		return nil, err
 	}
 
-	p := parser.New(tokens)
-	v, err := p.Parse()
+	return ast.Value(file).(map[string]interface{}), nil
+}
+
+// parseAST parses the input into a syntax tree, see parse
+func parseAST(filename string, r io.Reader) (*ast.File, error) {
+	l := lexer.NewReader(r)
+	l.Filename = filename
+
+	p := parser.NewStream(l)
+	file, err := p.ParseAST()
 	if err != nil {
-		return nil, fmt.Errorf("could not parse: %w", err)
+		var sourceErr *parser.SourceError
+		var lexErr *lexer.Error
+		var syntaxErr *SyntaxError
+		var duplicateErr *DuplicateKeyError
+		var indentationErr *IndentationError
+
+		switch {
+		case errors.As(err, &sourceErr) && errors.As(err, &lexErr):
+			return nil, &SyntaxError{
+				Position: lexErr.Position,
+				Path:     sourceErr.Path,
+				Token: token.Token{
+					TokenType: token.ILLEGAL,
+					Literal:   lexErr.Msg,
+					Position:  lexErr.Position,
+				},
+				Msg: lexErr.Msg,
+			}
+		case errors.As(err, &syntaxErr),
+			errors.As(err, &duplicateErr),
+			errors.As(err, &indentationErr):
+			return nil, err
+		}
+
+		if errors.As(err, &sourceErr) {
+			err = sourceErr.Err
+		}
+		return nil, fmt.Errorf("could not read input: %w", err)
 	}
 
-	return v, nil
+	return file, nil
 }
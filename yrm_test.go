@@ -1,7 +1,13 @@
 package yrm
 
 import (
+	"errors"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	check "gitlab.com/MaxIV/lib-maxiv-go-check"
 )
@@ -36,3 +42,238 @@ func TestYrm(t *testing.T) {
 	}
 	check.Equals(t, exp, m)
 }
+
+var listInput = `
+hosts:
+	- "alpha"
+	- "beta"
+servers:
+	-
+		host: "localhost"
+		ports:
+			- 80
+			- 443
+	-
+		host: "remote"
+		ports:
+			-
+				- 8080
+				- 8081
+`
+
+func TestYrmList(t *testing.T) {
+	m, err := Parse(listInput)
+	check.OK(t, err)
+
+	exp := map[string]interface{}{
+		"hosts": []interface{}{"alpha", "beta"},
+		"servers": []interface{}{
+			map[string]interface{}{
+				"host":  "localhost",
+				"ports": []interface{}{80, 443},
+			},
+			map[string]interface{}{
+				"host": "remote",
+				"ports": []interface{}{
+					[]interface{}{8080, 8081},
+				},
+			},
+		},
+	}
+	check.Equals(t, exp, m)
+}
+
+var flowInput = `
+ports: [80, 443]
+limits: {cpu: 1.5, memory: 512}
+servers:
+	- {host: "alpha", ports: [80]}
+	-
+		host: "beta"
+		tags: ["a", "b"]
+empty: {list: [], map: {}}
+`
+
+func TestYrmFlow(t *testing.T) {
+	m, err := Parse(flowInput)
+	check.OK(t, err)
+
+	exp := map[string]interface{}{
+		"ports":  []interface{}{80, 443},
+		"limits": map[string]interface{}{"cpu": 1.5, "memory": 512},
+		"servers": []interface{}{
+			map[string]interface{}{
+				"host":  "alpha",
+				"ports": []interface{}{80},
+			},
+			map[string]interface{}{
+				"host": "beta",
+				"tags": []interface{}{"a", "b"},
+			},
+		},
+		"empty": map[string]interface{}{
+			"list": []interface{}{},
+			"map":  map[string]interface{}{},
+		},
+	}
+	check.Equals(t, exp, m)
+}
+
+func TestYrmSpaces(t *testing.T) {
+	tabs := "foo:\n\tbar: 1\n\tlist:\n\t\t- 1\n\t\t-\n\t\t\tbaz: true\n"
+	spaces := strings.ReplaceAll(tabs, "\t", "    ")
+
+	exp, err := Parse(tabs)
+	check.OK(t, err)
+
+	m, err := Parse(spaces)
+	check.OK(t, err)
+	check.Equals(t, exp, m)
+
+	_, err = Parse("foo:\n    bar: 1\n\tbaz: 1\n")
+	check.NotOK(t, err)
+	check.Equals(t, "3:1: indented with tabs, but line 2 is indented with spaces", err.Error())
+
+	// blank lines can hold any whitespace
+	for _, blank := range []string{"\t", "\t\t", "  ", " \t"} {
+		m, err = Parse("a:\n\tb: 1\n" + blank + "\n\tc: 2\n" + blank)
+		check.OK(t, err)
+		check.Equals(t, map[string]interface{}{"a": map[string]interface{}{"b": 1, "c": 2}}, m)
+	}
+}
+
+func TestYrmNull(t *testing.T) {
+	m, err := Parse("a: null\nb: [null, 1]\nc: {d: null}\ne:\n\t- null\n")
+	check.OK(t, err)
+
+	exp := map[string]interface{}{
+		"a": nil,
+		"b": []interface{}{nil, 1},
+		"c": map[string]interface{}{"d": nil},
+		"e": []interface{}{nil},
+	}
+	check.Equals(t, exp, m)
+}
+
+func TestYrmNumbers(t *testing.T) {
+	m, err := Parse("mode: 0o755\nold: 0644\nmask: 0xFF_FF\nbits: 0b101\nbig: 1e9\nmax: 0xFFFFFFFFFFFFFFFF\nneg: -inf\n")
+	check.OK(t, err)
+
+	exp := map[string]interface{}{
+		"mode": 0755,
+		"old":  0644,
+		"mask": 0xFFFF,
+		"bits": 5,
+		"big":  1e9,
+		"max":  uint64(math.MaxUint64),
+		"neg":  math.Inf(-1),
+	}
+	check.Equals(t, exp, m)
+
+	m, err = Parse("a: nan\n")
+	check.OK(t, err)
+	check.Assert(t, math.IsNaN(m["a"].(float64)))
+
+	_, err = Parse("a: 0x1_0000_0000_0000_0000\n")
+	check.NotOK(t, err)
+	check.Equals(t, "1:4: integer 0x1_0000_0000_0000_0000 overflows uint64", err.Error())
+
+	_, err = Parse("a: -9223372036854775809\n")
+	check.NotOK(t, err)
+	check.Equals(t, "1:4: integer -9223372036854775809 overflows int64", err.Error())
+
+	// Values that used to be unsupported are written as literals
+	b, err := Marshal(map[string]interface{}{"a": math.Inf(1), "b": math.NaN(), "c": uint64(math.MaxUint64)})
+	check.OK(t, err)
+	check.Equals(t, "a: inf\nb: nan\nc: 18446744073709551615\n", string(b))
+}
+
+func TestYrmDates(t *testing.T) {
+	m, err := Parse("day: 2024-01-15\nat: 10:30:00\nexpires: 2024-01-15T10:30:00.5+02:00\n")
+	check.OK(t, err)
+
+	exp := map[string]interface{}{
+		"day":     Date{Year: 2024, Month: time.January, Day: 15},
+		"at":      time.Date(0, time.January, 1, 10, 30, 0, 0, time.UTC),
+		"expires": time.Date(2024, time.January, 15, 8, 30, 0, 5e8, time.UTC),
+	}
+	check.Equals(t, exp["day"], m["day"])
+	check.Equals(t, exp["at"], m["at"])
+	check.Assert(t, exp["expires"].(time.Time).Equal(m["expires"].(time.Time)))
+
+	_, err = Parse("a: 2024-02-30\n")
+	check.NotOK(t, err)
+	check.Equals(t, "1:4: invalid date 2024-02-30: day out of range", err.Error())
+
+	// the canonical form is written back
+	b, err := Marshal(m)
+	check.OK(t, err)
+	check.Equals(t, "at: 10:30:00\nday: 2024-01-15\nexpires: 2024-01-15T10:30:00.5+02:00\n", string(b))
+}
+
+func TestYrmKeys(t *testing.T) {
+	m, err := Parse("x-request-id: \"abc\"\nhttp2: true\n\"my key\": 5\nhosts: {\"db.local\": 1}\n")
+	check.OK(t, err)
+
+	exp := map[string]interface{}{
+		"x-request-id": "abc",
+		"http2":        true,
+		"my key":       5,
+		"hosts":        map[string]interface{}{"db.local": 1},
+	}
+	check.Equals(t, exp, m)
+
+	// keys are only quoted when they have to be
+	b, err := Marshal(m)
+	check.OK(t, err)
+	check.Equals(t, "hosts:\n\t\"db.local\": 1\nhttp2: true\n\"my key\": 5\nx-request-id: \"abc\"\n", string(b))
+}
+
+func TestYrmErrorPosition(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.yrm")
+	err := ioutil.WriteFile(filename, []byte("foo: 5\nbar:\n\tbaz: 1\n\tbaz: 2\n"), 0644)
+	check.OK(t, err)
+
+	_, err = ParseFile(filename)
+	check.NotOK(t, err)
+	check.Equals(t, filename+":4:2: duplicate key 'bar.baz'", err.Error())
+
+	_, err = Parse("foo: 5\n\t\tbar: 1\n")
+	check.NotOK(t, err)
+	check.Equals(t, "2:1: expected 0 levels of indentation, got 2", err.Error())
+}
+
+func TestYrmErrorTypes(t *testing.T) {
+	var err error
+
+	var dup *DuplicateKeyError
+	_, err = Parse("foo:\n\tbar: 1\n\tbaz: [1, {a: 1, a: 2}]\n")
+	check.AssertWithMessage(t, errors.As(err, &dup), "expected *DuplicateKeyError, got %v", err)
+	check.Equals(t, "a", dup.Key)
+	check.Equals(t, []string{"foo", "baz", "1", "a"}, dup.Path)
+	check.Equals(t, 3, dup.Position.Line)
+	check.Equals(t, 18, dup.Position.Column)
+
+	var indent *IndentationError
+	_, err = Parse("foo:\n\tbar:\n\t\t\tbaz: 1\n")
+	check.AssertWithMessage(t, errors.As(err, &indent), "expected *IndentationError, got %v", err)
+	check.Equals(t, 2, indent.Expected)
+	check.Equals(t, 3, indent.Got)
+	check.Equals(t, []string{"foo", "bar"}, indent.Path)
+	check.Equals(t, 3, indent.Position.Line)
+
+	var syntax *SyntaxError
+	_, err = Parse("foo:\n\tbar: \"unterminated\n")
+	check.AssertWithMessage(t, errors.As(err, &syntax), "expected *SyntaxError, got %v", err)
+	check.Equals(t, "2:7: unterminated quoted string", syntax.Error())
+	check.Equals(t, []string{"foo", "bar"}, syntax.Path)
+	check.Equals(t, 2, syntax.Position.Line)
+
+	_, err = Parse("foo: [1, 2\n")
+	check.AssertWithMessage(t, errors.As(err, &syntax), "expected *SyntaxError, got %v", err)
+	check.Equals(t, "1:11: unterminated flow collection", syntax.Error())
+
+	_, err = Parse("foo: 1\nbar: 2 3\n")
+	check.AssertWithMessage(t, errors.As(err, &syntax), "expected *SyntaxError, got %v", err)
+	check.Equals(t, []string{"bar"}, syntax.Path)
+}